@@ -33,7 +33,11 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"runtime"
+	"sort"
+	"strings"
+	"text/tabwriter"
 
 	. "github.com/frankkopp/MatchMaker/internal/config"
 	"github.com/frankkopp/MatchMaker/internal/livery"
@@ -41,6 +45,23 @@ import (
 	"github.com/frankkopp/MatchMaker/internal/ui"
 )
 
+// stringList is a command line flag which can be given several times. Each
+// value can also contain several entries separated by ";".
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ";")
+}
+
+func (l *stringList) Set(value string) error {
+	for _, v := range strings.Split(value, ";") {
+		if v = strings.TrimSpace(v); v != "" {
+			*l = append(*l, v)
+		}
+	}
+	return nil
+}
+
 const (
 	Version = "v1.1"
 	IniFile = "matchmaker.ini"
@@ -50,10 +71,27 @@ func main() {
 
 	// take care of command line argument
 	Configuration.IniFileName = flag.String("ini", IniFile, "path to ini file")
-	liveryDirectory := flag.String("dir", "", "path where liveries are searched recursively")
+	var liveryDirectories stringList
+	flag.Var(&liveryDirectories, "dir", "path where liveries are searched recursively - can be repeated or separated by \";\" (optional label as label=path)")
+	var archiveDirectories stringList
+	flag.Var(&archiveDirectories, "archives", "path where zip archives with liveries which are not installed are searched - can be repeated or separated by \";\"")
 	outputFile := flag.String("outputFile", "", "path and filename to output file")
+	var importFiles stringList
+	flag.Var(&importFiles, "import", "VMR file whose rules are merged into the generated rules - can be repeated or separated by \";\"")
+	merge := flag.String("merge", "", "rule used if a generated and an imported rule have the same CallsignPrefix and TypeCode: generated, imported or union")
 	noUI := flag.Bool("noUI", false, "does not use ui and starts directly with given configuration")
-	Configuration.Verbose = flag.Bool("verbose", false, "prints additional information to console")
+	Configuration.Rescan = flag.Bool("rescan", false, "ignores the scan cache and parses all aircraft.cfg files again")
+	reportFile := flag.String("report", "", "writes a scan report with all issues to the given file (\"-\" for console) - only with -noUI")
+	ignoreContentXml := flag.Bool("ignoreContentXml", false, "ignores the package activation state in the MSFS content.xml")
+	var columns stringList
+	flag.Var(&columns, "columns", "prints the scanned liveries with the given fields as columns (separated by \",\" or \";\") - only with -noUI\n"+
+		"fields: "+strings.Join(livery.Fields, ", "))
+	var filterExpressions stringList
+	flag.Var(&filterExpressions, "filter", "only lists liveries where the field contains the value (field=value or field!=value) - can be repeated")
+	diff := flag.Bool("diff", false, "compares the calculated rules with the output file and prints the added, removed and changed rules without saving")
+	watch := flag.Bool("watch", false, "keeps running without ui and regenerates the rules file when liveries or the ini file change")
+	workers := flag.Int("workers", -1, "number of parallel workers parsing aircraft.cfg files (0 = one per CPU)")
+	Configuration.Verbose = flag.Bool("verbose", false, "prints additional information to console - e.g. the number of liveries per status and reason why they are not used for rules")
 	versionInfo := flag.Bool("version", false, "prints version and exits")
 
 	flag.Parse()
@@ -71,19 +109,66 @@ func main() {
 	Configuration.LoadIni()
 
 	// overwrite the ini configuration with command line options
-	if *liveryDirectory != "" {
-		Configuration.SetLiveryDirectory(*liveryDirectory)
+	// watch mode applies them again when the ini file is reloaded
+	applyCommandLineOptions := func() {
+		if len(liveryDirectories) > 0 {
+			Configuration.SetLiveryRoots(liveryDirectories)
+		}
+		if len(archiveDirectories) > 0 {
+			Configuration.SetArchiveDirs(archiveDirectories)
+		}
+		if *outputFile != "" {
+			Configuration.SetOutputFile(*outputFile)
+		}
+		if len(importFiles) > 0 {
+			Configuration.SetImportFiles(importFiles)
+		}
+		if *merge != "" {
+			Configuration.SetMergeStrategy(*merge)
+		}
+		if *ignoreContentXml {
+			Configuration.SetIgnoreContentXml(true)
+		}
+		if *workers >= 0 {
+			Configuration.SetScanWorkers(*workers)
+		}
 	}
-	if *outputFile != "" {
-		Configuration.SetOutputFile(*outputFile)
+	applyCommandLineOptions()
+
+	// columns and filters for listing the scanned liveries
+	var listColumns []string
+	for _, c := range columns {
+		for _, f := range strings.Split(c, ",") {
+			if f = strings.TrimSpace(f); f != "" {
+				if !livery.IsField(f) {
+					log.Fatalf("Unknown column %q - valid fields are %s", f, strings.Join(livery.Fields, ", "))
+				}
+				listColumns = append(listColumns, f)
+			}
+		}
+	}
+	var filters []livery.Filter
+	for _, e := range filterExpressions {
+		filter, err := livery.ParseFilter(e)
+		if err != nil {
+			log.Fatal(err)
+		}
+		filters = append(filters, filter)
 	}
 
 	// Command line processing without any UI
-	if *noUI {
-		if err := commandLineProcessing(); err != nil {
+	if *noUI || *watch || *diff {
+		result, err := commandLineProcessing(*reportFile, listColumns, filters, *diff && !*watch, *watch)
+		if err != nil {
 			log.Print(err)
 			os.Exit(1)
 		}
+		if *watch {
+			if err := watchProcessing(result, applyCommandLineOptions); err != nil {
+				log.Print(err)
+				os.Exit(1)
+			}
+		}
 		os.Exit(0)
 	}
 
@@ -94,38 +179,258 @@ func main() {
 	}
 }
 
-func commandLineProcessing() error {
+func commandLineProcessing(reportFile string, listColumns []string, filters []livery.Filter, diffOnly bool, saveIfChanged bool) (*livery.ScanResult, error) {
 	fmt.Printf("vPilot MatchMaker by Frank Kopp %s\n", Version)
 	fmt.Println("======================================================================================")
 
 	// Step 1: search for liveries
-	fmt.Printf("Searching for liveries in folder %s...\n",
-		Configuration.Ini.Section("paths").Key("liveryDir").Value())
-	liveries, err := livery.ScanLiveryFolder(
-		Configuration.Ini.Section("paths").Key("liveryDir").Value())
+	roots := Configuration.LiveryRoots()
+	for _, root := range roots {
+		if root.Enabled {
+			fmt.Printf("Searching for liveries in folder %s (%s)...\n", root.Path, root.Label)
+		} else {
+			fmt.Printf("Skipping disabled folder %s (%s)\n", root.Path, root.Label)
+		}
+	}
+	result, err := livery.ScanLiveryRoots(roots)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	if len(result.Archived) > 0 {
+		result.ArchiveGaps = rules.NewEngine(rules.NewConfig(&Configuration)).ArchiveGaps(result.Liveries, result.Archived)
+	}
+	liveries := result.Liveries
 	fmt.Printf("Found %d liveries.\n", len(liveries))
+	for _, line := range result.CategorySummary() {
+		fmt.Printf("  %s\n", line)
+	}
+	fmt.Printf("Skipped %d files, %d issues found.\n", result.SkippedFiles(), len(result.Issues))
+	for _, line := range result.IssueSummary() {
+		fmt.Printf("  %s\n", line)
+	}
+	if len(result.Pruned) > 0 {
+		fmt.Printf("Pruned paths by include/exclude rules:\n")
+		for _, line := range result.PrunedSummary() {
+			fmt.Printf("  %s\n", line)
+		}
+	}
+	if reportFile != "" {
+		if err := writeReport(result, reportFile); err != nil {
+			return nil, err
+		}
+	}
+	printRootSummary(roots, liveries)
+	if len(result.Archived) > 0 {
+		printArchiveGaps(result)
+	}
+	if *Configuration.Verbose {
+		printReasonSummary(liveries)
+		printPackageSummary(liveries)
+	}
+	if len(listColumns) > 0 || len(filters) > 0 {
+		printLiveryList(livery.FilterLiveries(liveries, filters), listColumns)
+	}
 
 	// Step 2: calculate rules
 	fmt.Printf("Calculating rules...\n")
-	rules.CalculateRules(liveries)
-	fmt.Printf("Calculated %d rules.\n", rules.Counter)
+	ruleSet, err := calculateRules(liveries)
+	if err != nil {
+		return nil, err
+	}
+	stats := ruleSet.Stats()
+	fmt.Printf("Calculated %d rules for %d liveries and %d ICAOs.\n", stats.Mappings, stats.Liveries, stats.Icaos)
+	if stats.Fallbacks > 0 {
+		fmt.Printf("%d ICAO and type codes use the liveries of a fallback ICAO.\n", stats.Fallbacks)
+	}
+	if stats.CrossBase > 0 {
+		fmt.Printf("%d ICAO and type codes use the liveries of the ICAO on another base container.\n", stats.CrossBase)
+	}
+	if stats.Imported > 0 {
+		fmt.Printf("Merged %d imported rules (%s).\n", stats.Imported, Configuration.MergeStrategy())
+	}
 
-	// Step 3: write rules to file as XML
+	// Step 3: compare with the current rules file - the complete changes are only
+	// printed for -diff or -verbose
 	outputFile := Configuration.Ini.Section("paths").Key("outputFile").Value()
+	diff, issues, err := ruleSet.DiffFile(outputFile)
+	if err != nil {
+		fmt.Printf("Could not compare the rules with %s: %v\n", outputFile, err)
+	} else {
+		for _, issue := range issues {
+			fmt.Printf("  %s\n", issue)
+		}
+		if diffOnly || *Configuration.Verbose {
+			if err := diff.WriteReport(os.Stdout); err != nil {
+				return nil, err
+			}
+		} else {
+			fmt.Printf("Rules compared to %s: %s\n", outputFile, diff.Summary())
+		}
+	}
+	if diffOnly {
+		fmt.Printf("DONE\n")
+		return result, nil
+	}
+
+	// Step 4: write rules to file as XML - in watch mode only if the rules have changed
 	fmt.Printf("Saving vmr file to %s...\n", outputFile)
-	_, number := rules.GenerateXML()
-	err = rules.SaveRulesToFile()
+	saved := true
+	if saveIfChanged {
+		saved, err = ruleSet.SaveToFileIfChanged(outputFile)
+	} else {
+		err = ruleSet.SaveToFile(outputFile)
+	}
 	if err != nil {
 		fmt.Printf("Failed to save rules to file%s\n", outputFile)
-		return err
+		return nil, err
+	}
+	if saved {
+		fmt.Printf("Rules file written to %s (%d XML rules).\n", outputFile, stats.IcaoRules)
+	} else {
+		fmt.Printf("Rules file %s is unchanged (%d XML rules).\n", outputFile, stats.IcaoRules)
 	}
-	fmt.Printf("Rules file written to %s (%d XML rules).\n", outputFile, number)
 
 	fmt.Printf("DONE\n")
-	return nil
+	return result, nil
+}
+
+// calculates the rules and merges the rules of the import files - problems with
+// imported rules are printed
+func calculateRules(liveries []*livery.Livery) (*rules.RuleSet, error) {
+	ruleSet := rules.NewEngine(rules.NewConfig(&Configuration)).Calculate(liveries)
+	files := Configuration.ImportFiles()
+	if len(files) == 0 {
+		return ruleSet, nil
+	}
+	merged, issues, err := ruleSet.MergeFiles(files, Configuration.MergeStrategy())
+	for _, issue := range issues {
+		fmt.Printf("  %s\n", issue)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("importing rules failed: %w", err)
+	}
+	return merged, nil
+}
+
+// writes the detailed scan report to the file or to the console if the file is "-"
+func writeReport(result *livery.ScanResult, reportFile string) error {
+	if reportFile == "-" {
+		return result.WriteReport(os.Stdout, true)
+	}
+	f, err := os.Create(reportFile)
+	if err != nil {
+		return err
+	}
+	if err := result.WriteReport(f, true); err != nil {
+		f.Close()
+		return err
+	}
+	fmt.Printf("Scan report written to %s\n", reportFile)
+	return f.Close()
+}
+
+// prints the number of liveries found per livery root and in verbose mode each livery
+func printRootSummary(roots []LiveryRoot, liveries []*livery.Livery) {
+	found := map[string]int{}
+	duplicates := map[string]int{}
+	inactive := map[string]int{}
+	missingBase := map[string]int{}
+	for _, l := range liveries {
+		found[l.Root]++
+		if l.DuplicateOf != "" {
+			duplicates[l.Root]++
+		}
+		if l.PackageInactive {
+			inactive[l.Root]++
+		}
+		if l.BaseMissing {
+			missingBase[l.Root]++
+		}
+		if *Configuration.Verbose {
+			fmt.Printf("  [%s] [%s] %s (%s) %s\n", l.Root, l.Package.Name, l.Title, l.Icao, l.AircraftCfgFile)
+			if l.BaseAircraft != nil {
+				fmt.Printf("      base aircraft: %s in %s\n", l.BaseAircraft, l.BaseAircraft.Root)
+			}
+			if !l.Included() {
+				fmt.Printf("      %s: %s\n", l.Status(), l.Remark())
+			}
+		}
+	}
+	for _, root := range roots {
+		if root.Enabled {
+			fmt.Printf("  %-20s %5d liveries (%d duplicates, %d in disabled packages, %d without base aircraft)\n",
+				root.Label+":", found[root.Label], duplicates[root.Label], inactive[root.Label], missingBase[root.Label])
+		}
+	}
+}
+
+// prints the number of liveries per status and why liveries are not used for rules
+func printReasonSummary(liveries []*livery.Livery) {
+	statuses := map[livery.Status]int{}
+	for _, l := range liveries {
+		statuses[l.Status()]++
+	}
+	fmt.Printf("Liveries used for rules: %d included, %d disabled, %d incomplete, %d blocked\n", statuses[livery.StatusIncluded],
+		statuses[livery.StatusDisabled], statuses[livery.StatusIncomplete], statuses[livery.StatusBlocked])
+	for _, c := range livery.CountReasons(liveries) {
+		fmt.Printf("  %-32s %5d liveries (%s)\n", c.Reason.String()+":", c.Count, c.Reason.Status())
+	}
+}
+
+// prints the archives which have liveries for ICAO and type codes without installed livery
+func printArchiveGaps(result *livery.ScanResult) {
+	fmt.Printf("Found %d liveries in archives which are not installed.\n", len(result.Archived))
+	if len(result.ArchiveGaps) == 0 {
+		fmt.Printf("  No archive has liveries for ICAO and type codes without installed livery.\n")
+		return
+	}
+	fmt.Printf("Archives with liveries for ICAO and type codes without installed livery:\n")
+	for _, gap := range result.ArchiveGaps {
+		fmt.Printf("  %-50s %4d liveries, %4d ICAO/type codes\n", filepath.Base(gap.Archive), gap.Liveries, len(gap.Fills))
+		if *Configuration.Verbose {
+			fmt.Printf("      %s\n", strings.Join(gap.Fills, ", "))
+		}
+	}
+}
+
+// prints the number of liveries and the meta data of each package
+func printPackageSummary(liveries []*livery.Livery) {
+	var packages []*livery.Package
+	count := map[*livery.Package]int{}
+	for _, l := range liveries {
+		if count[l.Package] == 0 {
+			packages = append(packages, l.Package)
+		}
+		count[l.Package]++
+	}
+	sort.Slice(packages, func(i, j int) bool { return packages[i].Name < packages[j].Name })
+	fmt.Printf("Found %d packages:\n", len(packages))
+	for _, p := range packages {
+		fmt.Printf("  %-50s %4d liveries  %s", p.Name, count[p], p.String())
+		if p.Creator != "" {
+			fmt.Printf(" by %s", p.Creator)
+		}
+		fmt.Println()
+	}
+}
+
+// prints the liveries as a tab separated table with the given fields as columns.
+// Without columns title, icao_airline and file are printed.
+func printLiveryList(liveries []*livery.Livery, columns []string) {
+	if len(columns) == 0 {
+		columns = []string{"title", "icao_airline", "file"}
+	}
+	fmt.Printf("Listing %d liveries:\n", len(liveries))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(columns, "\t"))
+	for _, l := range liveries {
+		values := make([]string, len(columns))
+		for i, c := range columns {
+			values[i] = l.Field(c)
+		}
+		fmt.Fprintln(w, strings.Join(values, "\t"))
+	}
+	w.Flush()
 }
 
 func printVersionInfo() {
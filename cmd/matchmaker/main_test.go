@@ -27,4 +27,25 @@
 
 package main
 
-// TODO: tests
+import (
+	"flag"
+	"reflect"
+	"testing"
+)
+
+func TestStringListFlag(t *testing.T) {
+	var dirs stringList
+	flags := flag.NewFlagSet("matchmaker", flag.ContinueOnError)
+	flags.Var(&dirs, "dir", "")
+	err := flags.Parse([]string{"-dir", `C:\MSFS\Community`, "-dir", `Official=D:\Official\OneStore; E:\Liveries ;`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := stringList{`C:\MSFS\Community`, `Official=D:\Official\OneStore`, `E:\Liveries`}
+	if !reflect.DeepEqual(dirs, want) {
+		t.Errorf("-dir = %v, want %v", dirs, want)
+	}
+	if got := dirs.String(); got != `C:\MSFS\Community;Official=D:\Official\OneStore;E:\Liveries` {
+		t.Errorf("String() = %s", got)
+	}
+}
@@ -0,0 +1,191 @@
+/*
+ * MatchMaker - create model matching files for VATSIM vPilot
+ *
+ *  MIT License
+ *
+ *  Copyright (c) 2021 Frank Kopp
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NON-INFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+
+	. "github.com/frankkopp/MatchMaker/internal/config"
+	"github.com/frankkopp/MatchMaker/internal/livery"
+	"github.com/frankkopp/MatchMaker/internal/watch"
+)
+
+// watchProcessing keeps running and regenerates the rules file whenever the
+// liveries in the livery roots or the ini file change. Changes are collected until
+// no further change happened for the configured watch delay. The rules file is
+// only written if the generated rules are different from the file's content.
+// When the ini file changes it is loaded again and the command line options are
+// applied again on top of it.
+func watchProcessing(previous *livery.ScanResult, applyCommandLineOptions func()) error {
+	iniFile, _ := filepath.Abs(*Configuration.IniFileName)
+	for {
+		roots := Configuration.EnabledLiveryRoots()
+		w, err := newWatcher(iniFile, roots)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Watching %d livery roots and %s for changes (Ctrl+C to stop)...\n", len(roots), iniFile)
+
+		// the watcher is recreated when the livery roots change
+		done := make(chan struct{})
+		w.Run(done, func(changed []string) {
+			iniChanged := false
+			for _, c := range changed {
+				if c == iniFile {
+					iniChanged = true
+				}
+			}
+			if iniChanged {
+				log.Printf("%s changed - reloading configuration", iniFile)
+				Configuration.LoadIni()
+				applyCommandLineOptions()
+			}
+			result, err := regenerateRules(previous, len(changed))
+			if err != nil {
+				log.Printf("Regenerating rules failed: %v", err)
+				return
+			}
+			previous = result
+			if !reflect.DeepEqual(roots, Configuration.EnabledLiveryRoots()) {
+				close(done)
+			}
+		})
+		if err := w.Close(); err != nil {
+			return err
+		}
+	}
+}
+
+// newWatcher watches the enabled livery roots and the ini file. Files written by
+// MatchMaker itself are ignored in case they are within a livery root.
+func newWatcher(iniFile string, roots []LiveryRoot) (*watch.Watcher, error) {
+	w, err := watch.New(Configuration.WatchDelay())
+	if err != nil {
+		return nil, err
+	}
+	for _, root := range roots {
+		if err := w.AddTree(root.Path); err != nil {
+			w.Close()
+			return nil, err
+		}
+	}
+	// imported rules are part of the rules file as well
+	for _, f := range append([]string{iniFile}, Configuration.ImportFiles()...) {
+		if err := w.AddFile(f); err != nil {
+			w.Close()
+			return nil, err
+		}
+	}
+	outputFile := Configuration.Ini.Section("paths").Key("outputFile").String()
+	cacheFile := Configuration.ScanCacheFile()
+	for _, f := range []string{outputFile, outputFile + ".bak", cacheFile, cacheFile + ".tmp"} {
+		w.Ignore(f)
+	}
+	return w, nil
+}
+
+// regenerateRules scans the livery roots again, recalculates the rules and saves
+// them if they have changed. Logs a summary of the changes compared to the
+// previous scan.
+func regenerateRules(previous *livery.ScanResult, changedPaths int) (*livery.ScanResult, error) {
+	result, err := livery.ScanLiveryRoots(Configuration.LiveryRoots())
+	if err != nil {
+		return nil, err
+	}
+	ruleSet, err := calculateRules(result.Liveries)
+	if err != nil {
+		return nil, err
+	}
+	outputFile := Configuration.Ini.Section("paths").Key("outputFile").String()
+	diff, _, diffErr := ruleSet.DiffFile(outputFile)
+	saved, err := ruleSet.SaveToFileIfChanged(outputFile)
+	if err != nil {
+		return nil, err
+	}
+
+	added, removed, modified := compareLiveries(previous, result)
+	status := "rules unchanged"
+	if saved {
+		status = "rules file written"
+		if diffErr == nil {
+			status += " (" + diff.Summary() + ")"
+		}
+	}
+	log.Printf("%d changes: %d liveries (%d added, %d removed, %d changed), %d mappings - %s",
+		changedPaths, len(result.Liveries), len(added), len(removed), len(modified), ruleSet.Stats().Mappings, status)
+	if *Configuration.Verbose && saved && diffErr == nil {
+		if err := diff.WriteReport(os.Stdout); err != nil {
+			return nil, err
+		}
+	}
+	if *Configuration.Verbose {
+		for _, l := range added {
+			fmt.Printf("  + %s (%s) %s\n", l.Title, l.Icao, l.AircraftCfgFile)
+		}
+		for _, l := range removed {
+			fmt.Printf("  - %s (%s) %s\n", l.Title, l.Icao, l.AircraftCfgFile)
+		}
+		for _, l := range modified {
+			fmt.Printf("  ~ %s (%s) %s\n", l.Title, l.Icao, l.AircraftCfgFile)
+		}
+	}
+	return result, nil
+}
+
+// compareLiveries returns the liveries which have been added, removed or changed
+// in a way relevant for rules between two scans
+func compareLiveries(previous, current *livery.ScanResult) (added, removed, modified []*livery.Livery) {
+	before := map[string]*livery.Livery{}
+	if previous != nil {
+		for _, l := range previous.Liveries {
+			before[l.AircraftCfgFile] = l
+		}
+	}
+	for _, l := range current.Liveries {
+		old, found := before[l.AircraftCfgFile]
+		switch {
+		case !found:
+			added = append(added, l)
+		case old.Title != l.Title || old.Icao != l.Icao || old.BaseContainer != l.BaseContainer || old.Remark() != l.Remark():
+			modified = append(modified, l)
+		}
+		delete(before, l.AircraftCfgFile)
+	}
+	if previous != nil {
+		for _, l := range previous.Liveries {
+			if _, found := before[l.AircraftCfgFile]; found {
+				removed = append(removed, l)
+			}
+		}
+	}
+	return added, removed, modified
+}
@@ -3,6 +3,7 @@ module github.com/frankkopp/MatchMaker
 go 1.14
 
 require (
+	github.com/fsnotify/fsnotify v1.4.9
 	github.com/karrick/godirwalk v1.16.1
 	github.com/lxn/walk v0.0.0-20210112085537-c389da54e794
 	github.com/lxn/win v0.0.0-20210218163916-a377121e959e // indirect
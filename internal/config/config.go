@@ -36,7 +36,10 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"runtime"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/frankkopp/MatchMaker/internal/util"
 	"gopkg.in/ini.v1"
@@ -57,6 +60,7 @@ type Config struct {
 	Ini         *ini.File
 	Custom      *CustomData
 	Verbose     *bool
+	Rescan      *bool
 	Valid       bool
 	Dirty       bool
 }
@@ -74,6 +78,7 @@ func (c *Config) LoadIni() {
 	c.Ini = tmpIni
 	c.ExtractCustomDataFromIni()
 	c.Valid = c.validateIniConfig()
+	c.logIcaoConflicts()
 	c.Dirty = false
 }
 
@@ -88,25 +93,32 @@ func (c *Config) LoadFromString(iniString string) error {
 	c.Ini = tmpIni
 	c.ExtractCustomDataFromIni()
 	c.Valid = c.validateIniConfig()
+	c.logIcaoConflicts()
 	c.Dirty = true
 	return nil
 }
 
 // check configuration ini for the minimal settings to run meaningful
 func (c *Config) validateIniConfig() bool {
-	// livery directory
-	if c.Ini.Section("paths").Key("liveryDir").String() == "" {
+	// livery directories - at least one and all enabled have to exist
+	roots := c.EnabledLiveryRoots()
+	if len(roots) == 0 {
 		return false
 	}
-	isDir, err := util.IsDir(c.Ini.Section("paths").Key("liveryDir").String())
-	if err != nil || !isDir {
-		return false
+	for _, root := range roots {
+		if root.Path == "" {
+			return false
+		}
+		isDir, err := util.IsDir(root.Path)
+		if err != nil || !isDir {
+			return false
+		}
 	}
 	// output file
 	if c.Ini.Section("paths").Key("outputFile").String() == "" {
 		return false
 	}
-	isDir, err = util.IsDir(c.Ini.Section("paths").Key("outputFile").String())
+	isDir, err := util.IsDir(c.Ini.Section("paths").Key("outputFile").String())
 	if err != nil || isDir { // file does not exist or path exists but is directory
 		return false
 	}
@@ -189,18 +201,275 @@ func (c *Config) RemoveLiveryFromDefault(base string, title string) {
 	c.Dirty = true
 }
 
-// SetLiveryDirectory sets the liveryDir value in the paths sections of the ini
-func (c *Config) SetLiveryDirectory(s string) {
-	c.Ini.Section("paths").Key("liveryDir").SetValue(s)
-	c.Dirty = true
-}
-
 // SetOutputFile sets the outputFile value in the paths sections of the ini
 func (c *Config) SetOutputFile(s string) {
 	c.Ini.Section("paths").Key("outputFile").SetValue(s)
 	c.Dirty = true
 }
 
+// ScanWorkers returns the number of parallel workers used to parse aircraft.cfg files.
+// A value of 0 or less in the scan section of the ini means one worker per CPU.
+func (c *Config) ScanWorkers() int {
+	workers := c.Ini.Section("scan").Key("workers").MustInt(0)
+	if workers <= 0 {
+		return runtime.NumCPU()
+	}
+	return workers
+}
+
+// SetScanWorkers sets the workers value in the scan sections of the ini
+func (c *Config) SetScanWorkers(n int) {
+	c.Ini.Section("scan").Key("workers").SetValue(strconv.Itoa(n))
+	c.Dirty = true
+}
+
+// ScanCacheFile returns the path of the scan cache file from the scan section of
+// the ini. An empty value disables the scan cache.
+func (c *Config) ScanCacheFile() string {
+	return c.Ini.Section("scan").Key("cacheFile").String()
+}
+
+// RootPrecedence returns which livery root wins when the same livery title or
+// package is found in more than one root: "first" (default), "last" or "none" to
+// keep all copies.
+func (c *Config) RootPrecedence() string {
+	return c.Ini.Section("scan").Key("precedence").In("first", []string{"first", "last", "none"})
+}
+
+// ContentXmlFile returns the path of the MSFS content.xml file from the scan
+// section of the ini. If empty the file is searched next to the livery roots.
+func (c *Config) ContentXmlFile() string {
+	return c.Ini.Section("scan").Key("contentXml").String()
+}
+
+// IgnoreContentXml returns true if the package activation state of the MSFS
+// content.xml file should not be used
+func (c *Config) IgnoreContentXml() bool {
+	return c.Ini.Section("scan").Key("ignoreContentXml").MustBool(false)
+}
+
+// SetIgnoreContentXml sets the ignoreContentXml value in the scan sections of the ini
+func (c *Config) SetIgnoreContentXml(ignore bool) {
+	c.Ini.Section("scan").Key("ignoreContentXml").SetValue(strconv.FormatBool(ignore))
+	c.Dirty = true
+}
+
+// IncludeUserOnly returns true if variations which are not used for AI traffic
+// (isAirTraffic=0) should be used for rules
+func (c *Config) IncludeUserOnly() bool {
+	return c.Ini.Section("scan").Key("includeUserOnly").MustBool(true)
+}
+
+// IncludeAiOnly returns true if variations which can't be selected by the user
+// (isUserSelectable=0) should be used for rules
+func (c *Config) IncludeAiOnly() bool {
+	return c.Ini.Section("scan").Key("includeAiOnly").MustBool(true)
+}
+
+// ExcludeMissingBase returns true if liveries whose base aircraft is not installed
+// in any livery root should not be used for rules. Default true - the folder with
+// the base aircraft of MSFS (Official/OneStore) has to be a livery root.
+func (c *Config) ExcludeMissingBase() bool {
+	return c.Ini.Section("scan").Key("excludeMissingBase").MustBool(true)
+}
+
+// WatchDelay returns how long the watch mode waits after the last change of the
+// liveries or the ini file before it rescans
+func (c *Config) WatchDelay() time.Duration {
+	return c.Ini.Section("scan").Key("watchDelay").MustDuration(2 * time.Second)
+}
+
+// ScanIncludes returns the glob patterns of paths within the livery roots which
+// should be scanned. If there are none all paths are scanned.
+func (c *Config) ScanIncludes() []string {
+	return c.Ini.Section("scan").Key("include").Strings(",")
+}
+
+// ScanExcludes returns the glob patterns of paths within the livery roots which
+// should not be scanned
+func (c *Config) ScanExcludes() []string {
+	return c.Ini.Section("scan").Key("exclude").Strings(",")
+}
+
+// ArchiveDirs returns the folders which are searched for zip archives with
+// liveries which are not installed. Folders are separated by ";".
+func (c *Config) ArchiveDirs() []string {
+	var dirs []string
+	for _, dir := range c.Ini.Section("scan").Key("archiveDirs").Strings(";") {
+		if dir != "" {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// SetArchiveDirs sets the archiveDirs value in the scan sections of the ini
+func (c *Config) SetArchiveDirs(dirs []string) {
+	c.Ini.Section("scan").Key("archiveDirs").SetValue(strings.Join(dirs, ";"))
+	c.Dirty = true
+}
+
+// ImportFiles returns the VMR files which are merged into the generated rules.
+// Files are separated by ";".
+func (c *Config) ImportFiles() []string {
+	var files []string
+	for _, file := range c.Ini.Section("rules").Key("importFiles").Strings(";") {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// SetImportFiles sets the importFiles value in the rules section of the ini
+func (c *Config) SetImportFiles(files []string) {
+	c.Ini.Section("rules").Key("importFiles").SetValue(strings.Join(files, ";"))
+	c.Dirty = true
+}
+
+// MergeStrategy returns which rule is used if a generated and an imported rule
+// have the same CallsignPrefix and TypeCode: generated, imported or union
+func (c *Config) MergeStrategy() string {
+	return c.Ini.Section("rules").Key("merge").MustString("generated")
+}
+
+// SetMergeStrategy sets the merge value in the rules section of the ini
+func (c *Config) SetMergeStrategy(strategy string) {
+	c.Ini.Section("rules").Key("merge").SetValue(strategy)
+	c.Dirty = true
+}
+
+// PreferExactTypes returns true if a type code only uses the liveries of the
+// base containers which match it best ([exactTypes] or the position in the
+// type variations). Default false.
+func (c *Config) PreferExactTypes() bool {
+	return c.Ini.Section("rules").Key("preferExactTypes").MustBool(false)
+}
+
+// CrossBaseFallback returns true if an ICAO uses its liveries of the closest
+// other base container for type codes it has no livery for. [crossBaseIcaos]
+// overrides it per ICAO. Default false.
+func (c *Config) CrossBaseFallback() bool {
+	return c.Ini.Section("rules").Key("crossBaseFallback").MustBool(false)
+}
+
+// CrossBaseIcaos returns the ICAOs of [crossBaseIcaos] which enable or disable
+// the cross base fallback independent of [rules] crossBaseFallback
+func (c *Config) CrossBaseIcaos() map[string]bool {
+	icaos := map[string]bool{}
+	for _, key := range c.Ini.Section("crossBaseIcaos").Keys() {
+		if enabled, err := key.Bool(); err == nil {
+			icaos[key.Name()] = enabled
+		}
+	}
+	return icaos
+}
+
+// TypeFamily is a named list of similar base containers ordered by their similarity
+type TypeFamily struct {
+	Name           string
+	BaseContainers []string
+}
+
+// TypeFamilies returns the families of [typeFamilies] in the order of the ini
+func (c *Config) TypeFamilies() []TypeFamily {
+	var families []TypeFamily
+	for _, key := range c.Ini.Section("typeFamilies").Keys() {
+		families = append(families, TypeFamily{key.Name(), key.Strings(",")})
+	}
+	return families
+}
+
+// SubtypePattern limits the liveries with a title or ui_variation matching the
+// pattern to the type codes
+type SubtypePattern struct {
+	Pattern   string
+	TypeCodes []string
+}
+
+// SubtypePatterns returns the patterns of [subtypePatterns] in the order of the ini
+func (c *Config) SubtypePatterns() []SubtypePattern {
+	var patterns []SubtypePattern
+	for _, key := range c.Ini.Section("subtypePatterns").Keys() {
+		patterns = append(patterns, SubtypePattern{key.Name(), key.Strings(",")})
+	}
+	return patterns
+}
+
+// MaxTitlesPerRule returns the maximum number of different titles of a rule.
+// Default 0 - no limit.
+func (c *Config) MaxTitlesPerRule() int {
+	if n := c.Ini.Section("rules").Key("maxTitlesPerRule").MustInt(0); n > 0 {
+		return n
+	}
+	return 0
+}
+
+// TitleWeight is the weight of the liveries with a title matching the pattern
+type TitleWeight struct {
+	Pattern string
+	Weight  int
+}
+
+// TitleWeights returns the title patterns of [titleWeights] with their weights
+// in the order of the ini. Entries without a valid weight are ignored.
+func (c *Config) TitleWeights() []TitleWeight {
+	var weights []TitleWeight
+	for _, key := range c.Ini.Section("titleWeights").Keys() {
+		if weight, err := key.Int(); err == nil && weight >= 0 {
+			weights = append(weights, TitleWeight{key.Name(), weight})
+		}
+	}
+	return weights
+}
+
+// PackageWeights returns the weights of [packageWeights] mapped against the
+// package names. Entries without a valid weight are ignored.
+func (c *Config) PackageWeights() map[string]int {
+	weights := map[string]int{}
+	for _, key := range c.Ini.Section("packageWeights").Keys() {
+		if weight, err := key.Int(); err == nil && weight >= 0 {
+			weights[key.Name()] = weight
+		}
+	}
+	return weights
+}
+
+// Categories returns the lower case SimObject categories ([GENERAL] category of
+// the aircraft.cfg) which are used for rules. Default are airplanes and helicopters.
+func (c *Config) Categories() []string {
+	var categories []string
+	for _, category := range strings.Split(c.Ini.Section("scan").Key("categories").MustString("airplane, helicopter"), ",") {
+		if category = strings.ToLower(strings.TrimSpace(category)); category != "" {
+			categories = append(categories, category)
+		}
+	}
+	return categories
+}
+
+// IsCategory returns true if the SimObject category is used for rules
+func (c *Config) IsCategory(category string) bool {
+	for _, cat := range c.Categories() {
+		if strings.EqualFold(cat, category) {
+			return true
+		}
+	}
+	return false
+}
+
+// TypeVariationSections returns the ini sections which map base containers to
+// type codes: [typeVariations] and a [<category>TypeVariations] section for
+// each category, e.g. [helicopterTypeVariations]
+func (c *Config) TypeVariationSections() []*ini.Section {
+	sections := []*ini.Section{c.Ini.Section("typeVariations")}
+	for _, category := range c.Categories() {
+		if section, err := c.Ini.GetSection(category + "TypeVariations"); err == nil {
+			sections = append(sections, section)
+		}
+	}
+	return sections
+}
+
 // loads default configuration from a hard coded string containing a
 // default ini file structure
 func loadDefaults() *ini.File {
@@ -38,12 +38,16 @@ import (
 	"strings"
 )
 
+// NoWeight is the weight of an entry which does not change the weight of its livery
+const NoWeight = -1
+
 // Entry represents custom data for one particular livery
 type Entry struct {
 	AircraftCfgFile string
 	Process         bool
 	CustomIcao      string
 	OriginalIcao    string
+	Weight          int // how often the title is repeated in a rule - 0 drops it, NoWeight if not set
 }
 
 // CustomData holds a map of all entries mapped against their aircraft.cfg file-path
@@ -54,7 +58,7 @@ type CustomData struct {
 // newCustomData creates an instance of CustomData from a given string holding the
 // custom-data data structure
 // The custom-data data structure is a 1 or more lines containing a ,-separated list of
-// <aircraft.cfg-Filepath>,<process [true|false]>,<original icao code>, <custom icao code>
+// <aircraft.cfg-Filepath>,<process [true|false]>,<original icao code>, <custom icao code>[,<weight>]
 func newCustomData(body string) *CustomData {
 	newData := &CustomData{}
 	newData.data = map[string]*Entry{}
@@ -65,7 +69,7 @@ func newCustomData(body string) *CustomData {
 	for _, line := range lines {
 		tokens := strings.Split(line, ",")
 		if len(tokens) < 4 {
-			fmt.Sprintf("Custom data entry invalid: %s", line)
+			// invalid or empty line - e.g. the end marker of the section
 			continue
 		}
 		process, err := strconv.ParseBool(tokens[1])
@@ -77,6 +81,12 @@ func newCustomData(body string) *CustomData {
 			Process:         process,
 			OriginalIcao:    strings.TrimSpace(tokens[2]),
 			CustomIcao:      strings.TrimSpace(tokens[3]),
+			Weight:          NoWeight,
+		}
+		if len(tokens) > 4 {
+			if weight, err := strconv.Atoi(strings.TrimSpace(tokens[4])); err == nil && weight >= 0 {
+				entry.Weight = weight
+			}
 		}
 		newData.data[entry.AircraftCfgFile] = entry
 	}
@@ -90,17 +100,22 @@ func (d CustomData) NumberOfEntries() int {
 }
 
 // AddOrChangeEntry adds a new entry or changes an existing entry to the custom-data data structure.
-// When entry exists overwrites the complete entry with the given parameters.
+// When entry exists overwrites the complete entry with the given parameters but keeps its weight.
 // Updates the ini data structure (section "customData"
 func (d CustomData) AddOrChangeEntry(aircraftCfg string, process bool, originalIcao string, customIcao string) {
 	if aircraftCfg == "" {
 		return
 	}
+	weight := NoWeight
+	if d.HasEntry(aircraftCfg) {
+		weight = d.GetEntry(aircraftCfg).Weight
+	}
 	d.data[aircraftCfg] = &Entry{
 		AircraftCfgFile: aircraftCfg,
 		Process:         process,
 		CustomIcao:      customIcao,
 		OriginalIcao:    originalIcao,
+		Weight:          weight,
 	}
 	Configuration.UpdateIniCustomData()
 }
@@ -119,6 +134,28 @@ func (d CustomData) SetProcessFlag(aircraftCfg string, process bool, icao string
 	Configuration.UpdateIniCustomData()
 }
 
+// SetWeight creates a new custom-data entry or sets the weight of an existing entry.
+// NoWeight removes the weight.
+func (d CustomData) SetWeight(aircraftCfg string, weight int, icao string) {
+	if !d.HasEntry(aircraftCfg) {
+		d.AddOrChangeEntry(aircraftCfg, true, icao, "")
+	}
+	d.GetEntry(aircraftCfg).Weight = weight
+	Configuration.UpdateIniCustomData()
+}
+
+// Weights returns the weights of all entries which have one mapped against
+// their aircraft.cfg file-path
+func (d CustomData) Weights() map[string]int {
+	weights := map[string]int{}
+	for key, entry := range d.data {
+		if entry.Weight != NoWeight {
+			weights[key] = entry.Weight
+		}
+	}
+	return weights
+}
+
 // RemoveEntry removes an entry from the custom-data data structure.
 // Returns error if entry not found.
 // Updates the ini data structure (section "customData"
@@ -152,7 +189,11 @@ func (d CustomData) GetDataBody() string {
 	body := strings.Builder{}
 
 	for _, key := range sortKeys(d.data) {
-		fmt.Fprintf(&body, "%s,%t,%s,%s\r\n", key, d.data[key].Process, d.data[key].OriginalIcao, d.data[key].CustomIcao)
+		fmt.Fprintf(&body, "%s,%t,%s,%s", key, d.data[key].Process, d.data[key].OriginalIcao, d.data[key].CustomIcao)
+		if d.data[key].Weight != NoWeight {
+			fmt.Fprintf(&body, ",%d", d.data[key].Weight)
+		}
+		body.WriteString("\r\n")
 	}
 	return body.String()
 }
@@ -32,6 +32,53 @@ var defaultIni = `
 liveryDir = .
 outputFile = .\MatchMakingRulesUI.vmr
 
+[scan]
+# number of parallel workers parsing aircraft.cfg files (0 = one per CPU)
+workers = 0
+# file to store parsed aircraft.cfg data to speed up the next scan (empty = no cache)
+cacheFile = .\matchmaker.cache
+# which livery root wins if the same title or package is found in several roots: first, last or none
+precedence = first
+# MSFS content.xml with the activation state of packages (empty = search next to the livery roots)
+contentXml =
+# use all packages even if they are disabled in the content.xml
+ignoreContentXml = false
+# use variations which are not used for AI traffic in MSFS (isAirTraffic=0)
+includeUserOnly = true
+# use variations which can't be selected by the user in MSFS (isUserSelectable=0)
+includeAiOnly = true
+# do not use liveries whose base aircraft is not installed in any livery root - needs the folder with the
+# base aircraft (e.g. Official\OneStore) as livery root
+excludeMissingBase = true
+# command line -watch: time without further changes before the liveries are scanned again
+watchDelay = 2s
+# glob patterns of folders or files within the livery roots to scan (empty = all) - e.g. asobo-*, */SimObjects/**
+include =
+# glob patterns of folders or files within the livery roots not to scan - e.g. *-backup, dev/**
+exclude =
+# folders with zip archives of liveries which are not installed separated by ";" - shows which archives would fill gaps
+archiveDirs =
+# SimObject categories ([GENERAL] category of the base model) used for rules - type codes in [typeVariations] or [<category>TypeVariations]
+categories = airplane, helicopter
+
+[rules]
+# VMR files merged into the generated rules separated by ";" - e.g. hand written rules or rules of traffic packages
+importFiles =
+# rule used if a generated and an imported rule have the same CallsignPrefix and TypeCode: generated, imported or union (of the ModelNames)
+merge = generated
+# a type code only uses the liveries of the base containers which match it best ([exactTypes] or the position in
+# [typeVariations]) - other base containers are only used if an airline has no closer livery
+preferExactTypes = false
+# maximum number of different titles per rule - the titles with the highest weights are used (0 = no limit)
+maxTitlesPerRule = 0
+# an ICAO uses its liveries of the closest other base container ([typeFamilies] or the order of [typeVariations])
+# for type codes it has no livery for instead of the default livery - [crossBaseIcaos] overrides it per ICAO
+crossBaseFallback = false
+
+# optional list of folders to search for liveries in order of precedence - replaces liveryDir
+# <label> = <path>[,<enabled true|false>] - use "-" as label to use the folder name as label
+[liveryRoots]
+
 [defaultTypes]
 Asobo_A320_NEO = Airbus A320 Neo Asobo
 Asobo_B747_8i = Boeing 747-8i Asobo
@@ -56,6 +103,26 @@ Asobo_Longitude = C700,C750
 # Turbo Prop
 Asobo_TBM930 = TBM9
 
+# type variations of the helicopter category - the base container needs a default livery in [defaultTypes] as well
+[helicopterTypeVariations]
+# Asobo_H135 = EC35,EC45
+
+# with preferExactTypes: type codes a base container matches exactly - they rank before all [typeVariations]
+[exactTypes]
+# Asobo_A320_NEO = A20N,A320
+
+# with crossBaseFallback: <family> = <base container, ...> - similar base containers ordered by their similarity
+# a type code of a base container in a family only uses liveries of base containers of the same family
+[typeFamilies]
+# Widebody = Asobo_B747_8i,Asobo_B787_10
+
+# <pattern> = <type code, ...> - a livery whose title or ui_variation matches the first pattern only uses these type codes
+# of its base container instead of all - without * or ? the pattern matches if it is part of the title, case-insensitive
+[subtypePatterns]
+# A321neo = A21N
+# A321 = A321,A21N
+# A319 = A319,A19N
+
 [icaoVariations]
 Lufthansa = DLH,LHA,CLH
 BritishAirways = BAW,BA,SHT,CFE
@@ -69,6 +136,30 @@ Luxair = LUX,LGL
 WizzAir = WZZ,WUK
 VirginAtlantic = VIR,VOZ
 
+# <icao> = <group> - the group of [icaoVariations] an ICAO listed in several groups belongs to
+# without a declaration it belongs to the first group listing it
+[icaoResolutions]
+# BA = BritishAirways
+
+# <icao> = <fallback icao, ...> - an ICAO without own liveries for a type code uses the liveries of the first
+# fallback which has some - the fallbacks of a fallback are tried before the next fallback
+[icaoFallbacks]
+# CLH = DLH
+# BA = BAW
+
+# <icao> = <true|false> - enables or disables the cross base fallback for an ICAO independent of [rules] crossBaseFallback
+[crossBaseIcaos]
+# DLH = true
+
+# <title pattern> = <weight> - how often the titles matching the pattern are repeated in a rule, 0 drops them
+# the first matching pattern is used - * matches any text (also "/"), ? any character, case-insensitive
+[titleWeights]
+# *Lufthansa*Retro* = 0
+
+# <package name> = <weight> - the weight of all liveries of a package (folder name in the Community folder)
+[packageWeights]
+# big-airline-pack = 1
+
 # this section is automatically managed by the UI - edit with care
 [customData]
 Do not delete this line due to a bug in the ini library,false,,
@@ -0,0 +1,162 @@
+/*
+ * MatchMaker - create model matching files for VATSIM vPilot
+ *
+ *  MIT License
+ *
+ *  Copyright (c) 2021 Frank Kopp
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NON-INFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ *
+ */
+
+package config
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// IcaoGroup is a named group of ICAOs of [icaoVariations] which use the same liveries
+type IcaoGroup struct {
+	Name  string
+	Icaos []string
+}
+
+// IcaoConflict is an ICAO which is listed in more than one group of [icaoVariations]
+type IcaoConflict struct {
+	Icao     string
+	Groups   []string // all groups listing the ICAO in the order of the ini
+	Group    string   // the group the ICAO belongs to
+	Declared string   // the group declared in [icaoResolutions] - empty if none
+}
+
+func (c IcaoConflict) String() string {
+	groups := strings.Join(c.Groups, ", ")
+	switch {
+	case c.Declared == c.Group:
+		return fmt.Sprintf("ICAO %s is in the groups %s - using %s as declared in [icaoResolutions]", c.Icao, groups, c.Group)
+	case c.Declared != "":
+		return fmt.Sprintf("ICAO %s is in the groups %s - [icaoResolutions] declares %s which does not list it - using %s", c.Icao, groups, c.Declared, c.Group)
+	default:
+		return fmt.Sprintf("ICAO %s is in the groups %s - using the first group %s - declare the group in [icaoResolutions]", c.Icao, groups, c.Group)
+	}
+}
+
+// Resolved returns true if the group of the ICAO is declared in [icaoResolutions]
+func (c IcaoConflict) Resolved() bool {
+	return c.Declared == c.Group
+}
+
+// IcaoVariations returns the groups of [icaoVariations] in the order of the ini.
+// An ICAO listed in several groups only belongs to the group declared for it in
+// [icaoResolutions] or otherwise to the first group listing it. So each ICAO
+// is part of one group only.
+func (c *Config) IcaoVariations() []IcaoGroup {
+	groups, _ := c.resolveIcaoVariations()
+	return groups
+}
+
+// IcaoConflicts returns the ICAOs which are listed in more than one group of
+// [icaoVariations] in the order of their first occurrence
+func (c *Config) IcaoConflicts() []IcaoConflict {
+	_, conflicts := c.resolveIcaoVariations()
+	return conflicts
+}
+
+// UnresolvedIcaoConflicts returns the conflicts without a valid group in [icaoResolutions]
+func (c *Config) UnresolvedIcaoConflicts() []IcaoConflict {
+	var unresolved []IcaoConflict
+	for _, conflict := range c.IcaoConflicts() {
+		if !conflict.Resolved() {
+			unresolved = append(unresolved, conflict)
+		}
+	}
+	return unresolved
+}
+
+// logs each ICAO which is listed in more than one group of [icaoVariations]
+// without a valid resolution
+func (c *Config) logIcaoConflicts() {
+	for _, conflict := range c.UnresolvedIcaoConflicts() {
+		log.Printf("Conflict in [icaoVariations]: %s", conflict)
+	}
+}
+
+// resolveIcaoVariations reads the [icaoVariations] groups and assigns each ICAO
+// to exactly one group
+func (c *Config) resolveIcaoVariations() ([]IcaoGroup, []IcaoConflict) {
+	var groups []IcaoGroup
+	var order []string                // ICAOs in the order of their first occurrence
+	listedBy := map[string][]string{} // ICAO -> names of the groups listing it
+	for _, key := range c.Ini.Section("icaoVariations").Keys() {
+		group := IcaoGroup{Name: key.Name()}
+		for _, icao := range key.Strings(",") {
+			if icao == "" || contains(group.Icaos, icao) {
+				continue
+			}
+			group.Icaos = append(group.Icaos, icao)
+			if len(listedBy[icao]) == 0 {
+				order = append(order, icao)
+			}
+			listedBy[icao] = append(listedBy[icao], group.Name)
+		}
+		groups = append(groups, group)
+	}
+
+	resolutions := c.Ini.Section("icaoResolutions")
+	var conflicts []IcaoConflict
+	belongsTo := map[string]string{} // ICAO -> group for the ICAOs of conflicts
+	for _, icao := range order {
+		if len(listedBy[icao]) < 2 {
+			continue
+		}
+		conflict := IcaoConflict{Icao: icao, Groups: listedBy[icao], Group: listedBy[icao][0]}
+		if resolutions.HasKey(icao) {
+			conflict.Declared = resolutions.Key(icao).String()
+			if contains(conflict.Groups, conflict.Declared) {
+				conflict.Group = conflict.Declared
+			}
+		}
+		belongsTo[icao] = conflict.Group
+		conflicts = append(conflicts, conflict)
+	}
+
+	// remove the ICAOs of conflicts from all other groups
+	for i, group := range groups {
+		icaos := group.Icaos[:0:0]
+		for _, icao := range group.Icaos {
+			if g, ok := belongsTo[icao]; ok && g != group.Name {
+				continue
+			}
+			icaos = append(icaos, icao)
+		}
+		groups[i].Icaos = icaos
+	}
+	return groups, conflicts
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
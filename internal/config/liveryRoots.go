@@ -0,0 +1,116 @@
+/*
+ * MatchMaker - create model matching files for VATSIM vPilot
+ *
+ *  MIT License
+ *
+ *  Copyright (c) 2021 Frank Kopp
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NON-INFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ *
+ */
+
+package config
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// LiveryRoot is a directory which is searched recursively for liveries.
+// The order of the roots in the ini defines their precedence when the same
+// livery is found in more than one root.
+type LiveryRoot struct {
+	Label   string
+	Path    string
+	Enabled bool
+}
+
+// LiveryRoots returns the configured livery roots in the order of the ini section
+// "liveryRoots". Each key is the label of the root and the value is the path
+// optionally followed by ",true" or ",false" to enable or disable the root.
+// A key "-" has no label and the folder name of the path is used instead.
+// If the section is empty the liveryDir value of the paths section is used as the only root.
+func (c *Config) LiveryRoots() []LiveryRoot {
+	keys := c.Ini.Section("liveryRoots").Keys()
+	if len(keys) == 0 {
+		path := c.Ini.Section("paths").Key("liveryDir").String()
+		return []LiveryRoot{{Label: rootLabel("", path), Path: path, Enabled: true}}
+	}
+	var roots []LiveryRoot
+	for _, key := range keys {
+		path, enabled := splitRootValue(key.String())
+		roots = append(roots, LiveryRoot{
+			Label:   rootLabel(key.Name(), path),
+			Path:    path,
+			Enabled: enabled,
+		})
+	}
+	return roots
+}
+
+// EnabledLiveryRoots returns only the livery roots which are enabled
+func (c *Config) EnabledLiveryRoots() []LiveryRoot {
+	var roots []LiveryRoot
+	for _, root := range c.LiveryRoots() {
+		if root.Enabled {
+			roots = append(roots, root)
+		}
+	}
+	return roots
+}
+
+// SetLiveryRoots replaces the configured livery roots with the given directories.
+// Each directory can be prefixed with a label as "label=path".
+func (c *Config) SetLiveryRoots(dirs []string) {
+	c.Ini.DeleteSection("liveryRoots")
+	section := c.Ini.Section("liveryRoots")
+	for i, dir := range dirs {
+		label, path := "", dir
+		if idx := strings.Index(dir, "="); idx > 0 {
+			label, path = dir[:idx], dir[idx+1:]
+		}
+		label = rootLabel(label, path)
+		if section.HasKey(label) {
+			label += strconv.Itoa(i + 1)
+		}
+		section.Key(label).SetValue(path)
+	}
+	c.Dirty = true
+}
+
+// splits a liveryRoots value into the path and the enabled flag
+// the flag is optional and separated by the last "," of the value
+func splitRootValue(value string) (string, bool) {
+	if idx := strings.LastIndex(value, ","); idx >= 0 {
+		if enabled, err := strconv.ParseBool(strings.TrimSpace(value[idx+1:])); err == nil {
+			return strings.TrimSpace(value[:idx]), enabled
+		}
+	}
+	return strings.TrimSpace(value), true
+}
+
+// returns the label or the folder name of the path if there is no label
+// ini auto increment keys ("-") are named "#1", "#2", ... and are treated as no label
+func rootLabel(label string, path string) string {
+	if label == "" || label == "-" || strings.HasPrefix(label, "#") {
+		return filepath.Base(path)
+	}
+	return label
+}
@@ -0,0 +1,82 @@
+/*
+ * MatchMaker - create model matching files for VATSIM vPilot
+ *
+ *  MIT License
+ *
+ *  Copyright (c) 2021 Frank Kopp
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NON-INFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ *
+ */
+
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLiveryRoots(t *testing.T) {
+	tests := []struct {
+		name string
+		ini  string
+		want []LiveryRoot
+	}{
+		{"liveryDir", "[paths]\nliveryDir = /msfs/Community\n",
+			[]LiveryRoot{{"Community", "/msfs/Community", true}}},
+		{"liveryRoots", "[paths]\nliveryDir = /msfs/Community\n[liveryRoots]\n" +
+			"Community = /msfs/Community\n" +
+			"Official = /msfs/Official/OneStore, false\n" +
+			"- = /liveries/More Liveries,true\n" +
+			"Backup = /liveries/A320, A321\n",
+			[]LiveryRoot{
+				{"Community", "/msfs/Community", true},
+				{"Official", "/msfs/Official/OneStore", false},
+				{"More Liveries", "/liveries/More Liveries", true},
+				{"Backup", "/liveries/A320, A321", true},
+			}},
+	}
+	for _, tt := range tests {
+		if err := Configuration.LoadFromString(tt.ini); err != nil {
+			t.Fatal(err)
+		}
+		if got := Configuration.LiveryRoots(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: LiveryRoots() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	enabled := Configuration.EnabledLiveryRoots()
+	if len(enabled) != 3 || enabled[1].Label != "More Liveries" {
+		t.Errorf("EnabledLiveryRoots() = %v, want all but Official", enabled)
+	}
+}
+
+func TestSetLiveryRoots(t *testing.T) {
+	if err := Configuration.LoadFromString("[liveryRoots]\nOld = /old\n"); err != nil {
+		t.Fatal(err)
+	}
+	Configuration.SetLiveryRoots([]string{"/msfs/Community", "Official=/msfs/Official/OneStore", "/backup/Community"})
+	want := []LiveryRoot{
+		{"Community", "/msfs/Community", true},
+		{"Official", "/msfs/Official/OneStore", true},
+		{"Community3", "/backup/Community", true},
+	}
+	if got := Configuration.LiveryRoots(); !reflect.DeepEqual(got, want) {
+		t.Errorf("LiveryRoots() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,174 @@
+/*
+ * MatchMaker - create model matching files for VATSIM vPilot
+ *
+ *  MIT License
+ *
+ *  Copyright (c) 2021 Frank Kopp
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NON-INFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ *
+ */
+
+package livery
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/frankkopp/MatchMaker/internal/config"
+)
+
+// Aircraft is an installed base aircraft - an aircraft.cfg with a [GENERAL]
+// section with a category, e.g. airplane or helicopter. Liveries reference it
+// by their base_container.
+// The ICAO meta data of the [GENERAL] section can be used for type mapping.
+type Aircraft struct {
+	Name               string // folder name of the aircraft which is used as base container name
+	AircraftCfgFile    string
+	Category           string // SimObject category in lower case
+	IcaoTypeDesignator string
+	IcaoManufacturer   string
+	IcaoModel          string
+	IcaoEngineType     string
+
+	Root            string   `json:"-"` // label of the livery root the aircraft was found in
+	Package         *Package `json:"-"` // package the aircraft belongs to
+	PackageInactive bool     `json:"-"` // package is disabled in MSFS
+}
+
+// String returns the name of the aircraft and its ICAO type designator if available
+func (a *Aircraft) String() string {
+	if a == nil {
+		return ""
+	}
+	if a.IcaoTypeDesignator == "" {
+		return a.Name
+	}
+	return a.Name + " (" + a.IcaoTypeDesignator + ")"
+}
+
+// newAircraft creates the base aircraft from the [GENERAL] section of a plane's aircraft.cfg
+func newAircraft(aircraftCfgFile string, general *cfgSection) *Aircraft {
+	return &Aircraft{
+		Name:               filepath.Base(filepath.Dir(aircraftCfgFile)),
+		AircraftCfgFile:    aircraftCfgFile,
+		Category:           strings.ToLower(general.get("category")),
+		IcaoTypeDesignator: general.get("icao_type_designator"),
+		IcaoManufacturer:   general.get("icao_manufacturer"),
+		IcaoModel:          general.get("icao_model"),
+		IcaoEngineType:     general.get("icao_engine_type"),
+	}
+}
+
+// aircraftIndex finds the installed base aircraft of liveries. MSFS merges all
+// packages into one virtual file system, so a base_container like
+// "..\Asobo_A320_NEO" is resolved relative to the livery folder inside its package.
+// If the resolved path does not match any aircraft the base container name is
+// used as MSFS aircraft folder names are unique in practice.
+type aircraftIndex struct {
+	byPath map[string]*Aircraft // virtual path of the aircraft folder -> aircraft
+	byName map[string]*Aircraft // aircraft folder name -> aircraft
+}
+
+// newAircraftIndex creates the index from the aircraft of all roots. The roots
+// have to be given in order of precedence - the first aircraft found wins.
+// Aircraft of disabled packages are not installed for MSFS and are ignored.
+func newAircraftIndex(results []rootResult) *aircraftIndex {
+	idx := &aircraftIndex{byPath: map[string]*Aircraft{}, byName: map[string]*Aircraft{}}
+	for _, r := range results {
+		for _, a := range r.aircraft {
+			if a.PackageInactive {
+				continue
+			}
+			if p := virtualPath(a.Package, filepath.Dir(a.AircraftCfgFile)); p != "" {
+				if _, found := idx.byPath[p]; !found {
+					idx.byPath[p] = a
+				}
+			}
+			name := strings.ToLower(a.Name)
+			if _, found := idx.byName[name]; !found {
+				idx.byName[name] = a
+			}
+		}
+	}
+	return idx
+}
+
+// find returns the base aircraft of the livery or nil if it is not installed
+func (idx *aircraftIndex) find(l *Livery) *Aircraft {
+	if l.BaseContainerPath != "" {
+		if dir := virtualPath(l.Package, filepath.Dir(cfgFileOf(l))); dir != "" {
+			if a, found := idx.byPath[strings.ToLower(path.Join(dir, l.BaseContainerPath))]; found {
+				return a
+			}
+		}
+	}
+	return idx.byName[strings.ToLower(l.BaseContainer)]
+}
+
+// resolveBaseAircraft attaches the installed base aircraft to each livery. Liveries
+// whose base aircraft is not installed are marked and - if configured - excluded.
+// The issues for missing base aircraft are returned.
+func resolveBaseAircraft(results []rootResult, idx *aircraftIndex) []*Issue {
+	exclude := config.Configuration.ExcludeMissingBase()
+	var issues []*Issue
+	for _, r := range results {
+		for _, l := range r.liveries {
+			l.BaseAircraft = idx.find(l)
+			if l.BaseAircraft != nil && l.Category == "" {
+				l.Category = l.BaseAircraft.Category
+			}
+			if l.BaseAircraft != nil || l.BaseContainer == "" {
+				continue
+			}
+			l.BaseMissing = true
+			// duplicates and liveries of disabled packages are not used anyway
+			if l.DuplicateOf != "" || l.PackageInactive {
+				continue
+			}
+			issues = append(issues, newIssue(l.AircraftCfgFile, SeverityWarning, CategoryMissingBaseAircraft,
+				"base aircraft %s of %s is not installed", l.BaseContainer, l.Title))
+			l.SetReason(ReasonBaseMissing, exclude)
+		}
+	}
+	return issues
+}
+
+// virtualPath returns the lower case path of dir relative to its package which
+// is the path MSFS uses in its virtual file system. Returns "" if dir is not
+// within the package.
+func virtualPath(p *Package, dir string) string {
+	if p == nil || p.Path == "" {
+		return ""
+	}
+	rel, err := filepath.Rel(p.Path, dir)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return ""
+	}
+	return strings.ToLower(filepath.ToSlash(rel))
+}
+
+// cfgFileOf returns the path of the aircraft.cfg file of a livery without the FLTSIM index
+func cfgFileOf(l *Livery) string {
+	if idx := strings.LastIndex(l.AircraftCfgFile, ":"); idx > 0 {
+		return l.AircraftCfgFile[:idx]
+	}
+	return l.AircraftCfgFile
+}
@@ -0,0 +1,273 @@
+/*
+ * MatchMaker - create model matching files for VATSIM vPilot
+ *
+ *  MIT License
+ *
+ *  Copyright (c) 2021 Frank Kopp
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NON-INFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ *
+ */
+
+package livery
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// cfgFile is a lenient parser for the MSFS cfg syntax used by aircraft.cfg files.
+// Real world files often are not valid ini files: unbalanced quotes, inline
+// comments, duplicate keys, UTF-16 or Windows-1252 encoding. The parser accepts
+// all of these and reports what it had to correct as problems with line numbers.
+// Section and key names are case insensitive and stored in lower case.
+type cfgFile struct {
+	sections map[string]*cfgSection
+}
+
+// cfgSection is one [section] of a cfg file
+type cfgSection struct {
+	name   string
+	line   int
+	values map[string]cfgValue
+}
+
+// cfgValue is the normalized value of a key and the line it was found in
+type cfgValue struct {
+	value string
+	line  int
+}
+
+// cfgProblem is a problem found while parsing a cfg file
+type cfgProblem struct {
+	line    int
+	message string
+}
+
+// fltsimSection is a [FLTSIM.N] section and its index N
+type fltsimSection struct {
+	index   int
+	section *cfgSection
+}
+
+// parseAircraftCfg parses the content of an aircraft.cfg file
+func parseAircraftCfg(data []byte) (*cfgFile, []cfgProblem) {
+	cfg := &cfgFile{sections: map[string]*cfgSection{}}
+	var problems []cfgProblem
+	problem := func(line int, format string, a ...interface{}) {
+		problems = append(problems, cfgProblem{line, fmt.Sprintf(format, a...)})
+	}
+
+	var current *cfgSection
+	for i, line := range strings.Split(decodeCfg(data), "\n") {
+		lineNumber := i + 1
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == ';' || line[0] == '#' || strings.HasPrefix(line, "//") {
+			continue
+		}
+
+		// section header
+		if line[0] == '[' {
+			end := strings.IndexByte(line, ']')
+			if end < 0 {
+				problem(lineNumber, "section header without closing ]")
+				end = len(line)
+			}
+			name := strings.ToLower(strings.TrimSpace(line[1:end]))
+			if existing, found := cfg.sections[name]; found {
+				problem(lineNumber, "duplicate section [%s] (first in line %d) - sections are merged", name, existing.line)
+				current = existing
+				continue
+			}
+			current = &cfgSection{name: name, line: lineNumber, values: map[string]cfgValue{}}
+			cfg.sections[name] = current
+			continue
+		}
+
+		// key = value
+		eq := strings.IndexByte(line, '=')
+		if eq < 0 {
+			problem(lineNumber, "line is neither a section nor a key = value pair")
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(line[:eq]))
+		if key == "" {
+			problem(lineNumber, "value without a key")
+			continue
+		}
+		if current == nil {
+			problem(lineNumber, "key %s outside of a section", key)
+			continue
+		}
+		value, balanced := normalizeCfgValue(line[eq+1:])
+		if !balanced {
+			problem(lineNumber, "unbalanced quotes in value of %s", key)
+		}
+		if existing, found := current.values[key]; found {
+			problem(lineNumber, "duplicate key %s in [%s] (first in line %d) - first value is used", key, current.name, existing.line)
+			continue
+		}
+		current.values[key] = cfgValue{value, lineNumber}
+	}
+	return cfg, problems
+}
+
+// section returns the section with the given name or nil if there is none
+func (f *cfgFile) section(name string) *cfgSection {
+	return f.sections[strings.ToLower(name)]
+}
+
+// fltsimSections returns all [FLTSIM.N] sections sorted by N. Gaps in the
+// numbering are allowed.
+func (f *cfgFile) fltsimSections() []fltsimSection {
+	var fltsims []fltsimSection
+	for name, section := range f.sections {
+		if !strings.HasPrefix(name, "fltsim.") {
+			continue
+		}
+		index, err := strconv.Atoi(strings.TrimSpace(name[len("fltsim."):]))
+		if err != nil || index < 0 {
+			continue
+		}
+		fltsims = append(fltsims, fltsimSection{index, section})
+	}
+	sort.Slice(fltsims, func(i, j int) bool { return fltsims[i].index < fltsims[j].index })
+	return fltsims
+}
+
+// has returns true if the section exists and contains the key
+func (s *cfgSection) has(key string) bool {
+	if s == nil {
+		return false
+	}
+	_, found := s.values[strings.ToLower(key)]
+	return found
+}
+
+// get returns the value of the key or an empty string if the section or key does not exist
+func (s *cfgSection) get(key string) string {
+	if s == nil {
+		return ""
+	}
+	return s.values[strings.ToLower(key)].value
+}
+
+// getBool returns the value of the key as bool. MSFS uses 0 and 1 but true and
+// false are accepted as well. Returns the default if the key does not exist or is invalid.
+func (s *cfgSection) getBool(key string, def bool) bool {
+	if !s.has(key) {
+		return def
+	}
+	value, err := strconv.ParseBool(s.get(key))
+	if err != nil {
+		return def
+	}
+	return value
+}
+
+// normalizeCfgValue removes inline comments, surrounding whitespace and quotes
+// from a raw value. Returns false if the quotes of the value are unbalanced.
+// Quotes are only used to group text in MSFS cfg files and are never part of a value.
+func normalizeCfgValue(raw string) (string, bool) {
+	inQuote := false
+	end := len(raw)
+loop:
+	for i := 0; i < len(raw); i++ {
+		switch raw[i] {
+		case '"':
+			inQuote = !inQuote
+		case ';':
+			if !inQuote {
+				end = i
+				break loop
+			}
+		case '/':
+			// "//" starts a comment if it is not part of a word or a path
+			if !inQuote && i+1 < len(raw) && raw[i+1] == '/' && (i == 0 || raw[i-1] == ' ' || raw[i-1] == '\t') {
+				end = i
+				break loop
+			}
+		}
+	}
+	value := raw[:end]
+	balanced := strings.Count(value, "\"")%2 == 0
+	return strings.TrimSpace(cleanUp(value)), balanced
+}
+
+// decodeCfg converts the content of a cfg file to a string. It handles UTF-8 with
+// and without BOM, UTF-16 (little and big endian) and falls back to Windows-1252
+// for files which are not valid UTF-8. Line endings are normalized to "\n".
+func decodeCfg(data []byte) string {
+	var text string
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		text = string(data[3:])
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		text = decodeUtf16(data[2:], false)
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		text = decodeUtf16(data[2:], true)
+	case len(data) >= 2 && data[0] != 0 && data[1] == 0:
+		// UTF-16 little endian without BOM - ASCII characters have a 0 high byte
+		text = decodeUtf16(data, false)
+	case utf8.Valid(data):
+		text = string(data)
+	default:
+		text = decodeWindows1252(data)
+	}
+	return strings.ReplaceAll(text, "\r", "")
+}
+
+func decodeUtf16(data []byte, bigEndian bool) string {
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		if bigEndian {
+			units[i] = uint16(data[2*i])<<8 | uint16(data[2*i+1])
+		} else {
+			units[i] = uint16(data[2*i+1])<<8 | uint16(data[2*i])
+		}
+	}
+	return string(utf16.Decode(units))
+}
+
+// windows1252 maps the bytes 0x80-0x9F of Windows-1252 to unicode. All other
+// bytes are identical to their unicode code point.
+var windows1252 = [32]rune{
+	'\u20AC', '\uFFFD', '\u201A', '\u0192', '\u201E', '\u2026', '\u2020', '\u2021',
+	'\u02C6', '\u2030', '\u0160', '\u2039', '\u0152', '\uFFFD', '\u017D', '\uFFFD',
+	'\uFFFD', '\u2018', '\u2019', '\u201C', '\u201D', '\u2022', '\u2013', '\u2014',
+	'\u02DC', '\u2122', '\u0161', '\u203A', '\u0153', '\uFFFD', '\u017E', '\u0178',
+}
+
+func decodeWindows1252(data []byte) string {
+	var sb strings.Builder
+	sb.Grow(len(data))
+	for _, b := range data {
+		if b >= 0x80 && b < 0xA0 {
+			sb.WriteRune(windows1252[b-0x80])
+		} else {
+			sb.WriteRune(rune(b))
+		}
+	}
+	return sb.String()
+}
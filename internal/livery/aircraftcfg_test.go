@@ -0,0 +1,102 @@
+/*
+ * MatchMaker - create model matching files for VATSIM vPilot
+ *
+ *  MIT License
+ *
+ *  Copyright (c) 2021 Frank Kopp
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NON-INFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ *
+ */
+
+package livery
+
+import (
+	"testing"
+	"unicode/utf16"
+)
+
+func Test_parseAircraftCfg(t *testing.T) {
+	cfg, problems := parseAircraftCfg([]byte("" +
+		"[VARIATION]\r\n" +
+		"base_container = \"..\\Asobo_A320_NEO\" ; the base\r\n" +
+		"\r\n" +
+		"[FLTSIM.0]\r\n" +
+		"title = \"Airbus A320 Neo Lufthansa\r\n" +
+		"icao_airline = DLH // airline\r\n" +
+		"icao_airline = LHA\r\n" +
+		"[fltsim.2]\r\n" +
+		"Title = \"Airbus A320 Neo Lufthansa; Retro\"\r\n" +
+		"ICAO_Airline = DLH\r\n"))
+
+	if got := cfg.section("variation").get("BASE_CONTAINER"); got != "..\\Asobo_A320_NEO" {
+		t.Errorf("base_container = %q", got)
+	}
+	fltsims := cfg.fltsimSections()
+	if len(fltsims) != 2 || fltsims[0].index != 0 || fltsims[1].index != 2 {
+		t.Fatalf("fltsimSections() = %v, want FLTSIM.0 and FLTSIM.2", fltsims)
+	}
+	tests := []struct {
+		name    string
+		section *cfgSection
+		key     string
+		want    string
+	}{
+		{"unbalanced quote", fltsims[0].section, "title", "Airbus A320 Neo Lufthansa"},
+		{"comment and duplicate", fltsims[0].section, "icao_airline", "DLH"},
+		{"; in quotes", fltsims[1].section, "title", "Airbus A320 Neo Lufthansa; Retro"},
+		{"case insensitive", fltsims[1].section, "icao_airline", "DLH"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.section.get(tt.key); got != tt.want {
+				t.Errorf("get(%s) = %q, want %q", tt.key, got, tt.want)
+			}
+		})
+	}
+
+	// unbalanced quotes in line 5 and duplicate key in line 7
+	if len(problems) != 2 || problems[0].line != 5 || problems[1].line != 7 {
+		t.Errorf("problems = %v, want lines 5 and 7", problems)
+	}
+}
+
+func Test_decodeCfg(t *testing.T) {
+	utf16le := []byte{0xFF, 0xFE}
+	for _, u := range utf16.Encode([]rune("title = Café\r\n")) {
+		utf16le = append(utf16le, byte(u), byte(u>>8))
+	}
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"utf-8", []byte("title = Café\r\n"), "title = Café\n"},
+		{"utf-8 bom", append([]byte{0xEF, 0xBB, 0xBF}, []byte("title = Café")...), "title = Café"},
+		{"utf-16 le bom", utf16le, "title = Café\n"},
+		{"windows-1252", []byte("title = Caf\xE9 \x80"), "title = Café €"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decodeCfg(tt.data); got != tt.want {
+				t.Errorf("decodeCfg() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+/*
+ * MatchMaker - create model matching files for VATSIM vPilot
+ *
+ *  MIT License
+ *
+ *  Copyright (c) 2021 Frank Kopp
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NON-INFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ *
+ */
+
+package livery
+
+import (
+	"archive/zip"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/frankkopp/MatchMaker/internal/config"
+	"github.com/karrick/godirwalk"
+)
+
+// ArchiveGap lists the ICAO and type code combinations (e.g. "DLH/A20N") an
+// archive has liveries for but no installed livery covers. Installing the
+// archive would add rules for them. See rules.Engine.ArchiveGaps.
+type ArchiveGap struct {
+	Archive  string
+	Liveries int      // usable liveries in the archive
+	Fills    []string // ICAO/type code combinations without installed livery
+}
+
+// scanArchives searches the folders for zip archives and returns the liveries
+// of all aircraft.cfg files within them. The liveries are marked as not
+// installed and are never processed. Their other reasons are set like for
+// installed liveries. Archives which can't be read are reported as issues in
+// the result.
+func scanArchives(dirs []string, aircraft *aircraftIndex, result *ScanResult) []*Livery {
+	excludeMissingBase := config.Configuration.ExcludeMissingBase()
+	var archives []string
+	for _, dir := range dirs {
+		err := godirwalk.Walk(dir, &godirwalk.Options{
+			Callback: func(osPathname string, de *godirwalk.Dirent) error {
+				if de.IsRegular() && strings.EqualFold(filepath.Ext(osPathname), ".zip") {
+					archives = append(archives, osPathname)
+				}
+				return nil
+			},
+			Unsorted:            true,
+			FollowSymbolicLinks: true,
+		})
+		if err != nil {
+			result.Issues = append(result.Issues, newIssue(dir, SeverityError, CategoryUnparseable, "%v", err))
+		}
+	}
+	sort.Strings(archives)
+
+	var liveries []*Livery
+	for _, archive := range archives {
+		archived, err := scanArchive(archive)
+		if err != nil {
+			result.Issues = append(result.Issues, newIssue(archive, SeverityError, CategoryUnparseable, "%v", err))
+			continue
+		}
+		for _, l := range archived {
+			applyCustomData(l, config.Configuration.Custom)
+			applyVariationFilter(l)
+			l.BaseAircraft = aircraft.find(l)
+			l.BaseMissing = l.BaseAircraft == nil
+			l.SetReason(ReasonBaseMissing, l.BaseMissing && excludeMissingBase)
+			l.SetReason(ReasonArchived, true)
+		}
+		liveries = append(liveries, archived...)
+	}
+	return liveries
+}
+
+// scanArchive returns the liveries of all aircraft.cfg files in a zip archive.
+// The key of a livery is the path of the aircraft.cfg within the archive
+// appended to the archive path.
+func scanArchive(archive string) ([]*Livery, error) {
+	r, err := zip.OpenReader(archive)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	pkg := &Package{Name: strings.TrimSuffix(filepath.Base(archive), filepath.Ext(archive)), Path: archive}
+	var liveries []*Livery
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() || !strings.EqualFold(filepath.Base(filepath.FromSlash(f.Name)), config.FileName) {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		data, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		// problems of files which are not installed are not reported
+		archived, _, _ := processAircraftCfgData(filepath.Join(archive, filepath.FromSlash(f.Name)), data)
+		archived = usedCategory(archived)
+		for _, l := range archived {
+			l.Archive = archive
+			l.Root = "archive"
+			l.Package = pkg
+		}
+		liveries = append(liveries, archived...)
+	}
+	sort.SliceStable(liveries, func(i, j int) bool { return liveries[i].AircraftCfgFile < liveries[j].AircraftCfgFile })
+	return liveries, nil
+}
@@ -0,0 +1,131 @@
+/*
+ * MatchMaker - create model matching files for VATSIM vPilot
+ *
+ *  MIT License
+ *
+ *  Copyright (c) 2021 Frank Kopp
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NON-INFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ *
+ */
+
+package livery
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+
+	"github.com/frankkopp/MatchMaker/internal/util"
+)
+
+// cacheVersion is stored in the cache file. A cache with a different version is
+// ignored. Increase it when the Livery data read from the aircraft.cfg changes.
+const cacheVersion = 7
+
+// scanCache stores the parsed liveries of each aircraft.cfg file together with
+// the size and modification time of the file when it was parsed.
+type scanCache struct {
+	Version int
+	Files   map[string]*cacheEntry
+}
+
+// cacheEntry holds the parsed liveries of one aircraft.cfg file, the base aircraft
+// if the file is a plane and the issues found while parsing. Files which are not
+// liveries are stored as well (without liveries) to avoid parsing them again.
+type cacheEntry struct {
+	Size     int64
+	ModTime  int64
+	Liveries []*Livery
+	Aircraft *Aircraft `json:",omitempty"`
+	Issues   []*Issue
+}
+
+func newScanCache() *scanCache {
+	return &scanCache{
+		Version: cacheVersion,
+		Files:   map[string]*cacheEntry{},
+	}
+}
+
+// loadScanCache reads the cache file. If the file does not exist or can't be
+// read an empty cache is returned as the cache is only an optimization.
+func loadScanCache(path string) *scanCache {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return newScanCache()
+	}
+	cache := newScanCache()
+	if err := json.Unmarshal(data, cache); err != nil || cache.Version != cacheVersion || cache.Files == nil {
+		return newScanCache()
+	}
+	return cache
+}
+
+// save writes the cache to the given file
+func (c *scanCache) save(path string) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	return util.WriteFileAtomic(path, data)
+}
+
+// lookup returns the cache entry for the file if the file has not changed since it
+// has been cached. Returns nil otherwise.
+func (c *scanCache) lookup(path string, info os.FileInfo) *cacheEntry {
+	entry, found := c.Files[path]
+	if !found || entry.Size != info.Size() || entry.ModTime != info.ModTime().UnixNano() {
+		return nil
+	}
+	return entry
+}
+
+func newCacheEntry(info os.FileInfo, liveries []*Livery, aircraft *Aircraft, issues []*Issue) *cacheEntry {
+	return &cacheEntry{
+		Size:     info.Size(),
+		ModTime:  info.ModTime().UnixNano(),
+		Liveries: liveries,
+		Aircraft: aircraft,
+		Issues:   issues,
+	}
+}
+
+// copyLiveries returns copies of the cached liveries as the scan changes them
+// when applying custom data and the cache has to keep the data as read from file.
+func (e *cacheEntry) copyLiveries() []*Livery {
+	if len(e.Liveries) == 0 {
+		return nil
+	}
+	liveries := make([]*Livery, len(e.Liveries))
+	for i, l := range e.Liveries {
+		c := *l
+		liveries[i] = &c
+	}
+	return liveries
+}
+
+// copyAircraft returns a copy of the cached base aircraft for the same reason as copyLiveries
+func (e *cacheEntry) copyAircraft() *Aircraft {
+	if e.Aircraft == nil {
+		return nil
+	}
+	a := *e.Aircraft
+	return &a
+}
@@ -0,0 +1,132 @@
+/*
+ * MatchMaker - create model matching files for VATSIM vPilot
+ *
+ *  MIT License
+ *
+ *  Copyright (c) 2021 Frank Kopp
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NON-INFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ *
+ */
+
+package livery
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/frankkopp/MatchMaker/internal/config"
+)
+
+func TestScanCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	root := filepath.Join(dir, "Community")
+	cacheFile := filepath.Join(dir, "cache.json")
+	dlhCfg := filepath.Join(root, "dlh-pack", "SimObjects", "Airplanes", "Asobo_A320_NEO-DLH", "aircraft.cfg")
+	livery := func(title, icao string) string {
+		return "[VARIATION]\nbase_container = \"..\\Asobo_A320_NEO\"\n[FLTSIM.0]\ntitle = \"" + title + "\"\nicao_airline = \"" + icao + "\"\n"
+	}
+	writeFiles(t, root, map[string]string{
+		"dlh-pack/SimObjects/Airplanes/Asobo_A320_NEO-DLH/aircraft.cfg": livery("A320 Lufthansa", "DLH"),
+		"baw-pack/SimObjects/Airplanes/Asobo_A320_NEO-BAW/aircraft.cfg": livery("A320 British Airways", "BAW"),
+	})
+
+	type want struct {
+		parsed, cached int
+		liveries       []string
+	}
+	scan := func(step string, rescan bool, customData string, w want) {
+		setupConfig(t, "[scan]\ncacheFile = "+cacheFile+"\nignoreContentXml = true\nexcludeMissingBase = false\n"+
+			"[defaultTypes]\nAsobo_A320_NEO = Airbus A320 Neo Asobo\n"+
+			"[customData]\nDo not delete this line due to a bug in the ini library,false,,\n"+customData+"-- end of customData - do not delete --\n")
+		*config.Configuration.Rescan = rescan
+		result, err := ScanLiveryFolder(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var liveries []string
+		for _, l := range result.Liveries {
+			liveries = append(liveries, l.Title+" "+l.Icao+" "+l.Status().String())
+		}
+		if result.FilesParsed != w.parsed || result.FilesCached != w.cached || !reflect.DeepEqual(liveries, w.liveries) {
+			t.Errorf("%s: parsed %d, cached %d, liveries %v - want %d, %d, %v",
+				step, result.FilesParsed, result.FilesCached, liveries, w.parsed, w.cached, w.liveries)
+		}
+	}
+	included := []string{"A320 British Airways BAW included", "A320 Lufthansa DLH included"}
+
+	scan("first scan", false, "", want{2, 0, included})
+	scan("unchanged", false, "", want{0, 2, included})
+
+	// custom data is applied on top of the cached liveries and does not change the cache
+	scan("custom data", false, dlhCfg+":0,false,DLH,CLH\n", want{0, 2,
+		[]string{"A320 British Airways BAW included", "A320 Lufthansa CLH disabled"}})
+	scan("custom data removed", false, "", want{0, 2, included})
+
+	// a rescan ignores the cache
+	scan("rescan", true, "", want{2, 0, included})
+
+	// a changed size invalidates the entry
+	writeFiles(t, root, map[string]string{
+		"dlh-pack/SimObjects/Airplanes/Asobo_A320_NEO-DLH/aircraft.cfg": livery("A320 Lufthansa Retro", "DLH"),
+	})
+	scan("size changed", false, "", want{1, 1,
+		[]string{"A320 British Airways BAW included", "A320 Lufthansa Retro DLH included"}})
+
+	// a changed modification time with the same size invalidates the entry
+	writeFiles(t, root, map[string]string{
+		"dlh-pack/SimObjects/Airplanes/Asobo_A320_NEO-DLH/aircraft.cfg": livery("A320 Lufthansa Cargo", "DLH"),
+	})
+	modTime := time.Now().Add(time.Hour)
+	if err := os.Chtimes(dlhCfg, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	scan("modification time changed", false, "", want{1, 1,
+		[]string{"A320 British Airways BAW included", "A320 Lufthansa Cargo DLH included"}})
+	scan("unchanged again", false, "", want{0, 2,
+		[]string{"A320 British Airways BAW included", "A320 Lufthansa Cargo DLH included"}})
+
+	// a cache of another version is ignored
+	cache := loadScanCache(cacheFile)
+	if len(cache.Files) != 2 {
+		t.Fatalf("loadScanCache() has %d files, want 2", len(cache.Files))
+	}
+	cache.Version = cacheVersion - 1
+	data, err := json.Marshal(cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(cacheFile, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if cache := loadScanCache(cacheFile); cache.Version != cacheVersion || len(cache.Files) != 0 {
+		t.Errorf("loadScanCache() of an old version = %d files, want none", len(cache.Files))
+	}
+	scan("old cache version", false, "", want{2, 0,
+		[]string{"A320 British Airways BAW included", "A320 Lufthansa Cargo DLH included"}})
+}
@@ -0,0 +1,124 @@
+/*
+ * MatchMaker - create model matching files for VATSIM vPilot
+ *
+ *  MIT License
+ *
+ *  Copyright (c) 2021 Frank Kopp
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NON-INFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ *
+ */
+
+package livery
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/frankkopp/MatchMaker/internal/config"
+	"github.com/frankkopp/MatchMaker/internal/util"
+)
+
+// ContentXmlFile is the file name of the MSFS file listing all packages and their activation state
+const ContentXmlFile = "content.xml"
+
+// contentXml represents the content.xml file MSFS uses to store which packages
+// are active. E.g.:
+//
+//	<Content>
+//	  <Package name="asobo-aircraft-a320-neo" active="true"/>
+//	  <Package name="my-livery-pack" active="false"/>
+//	</Content>
+type contentXml struct {
+	Packages []struct {
+		Name   string `xml:"name,attr"`
+		Active string `xml:"active,attr"`
+	} `xml:"Package"`
+}
+
+// loadInactivePackages reads a content.xml file and returns the names of all
+// packages which are not active. Names are lower case as MSFS does not care about
+// the case of package folders.
+func loadInactivePackages(path string) (map[string]bool, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var content contentXml
+	if err := xml.Unmarshal(data, &content); err != nil {
+		return nil, err
+	}
+	inactive := map[string]bool{}
+	for _, p := range content.Packages {
+		if strings.EqualFold(p.Active, "false") {
+			inactive[strings.ToLower(p.Name)] = true
+		}
+	}
+	return inactive, nil
+}
+
+// findContentXml returns the path of the content.xml file for a livery root.
+// A configured file is always used. Otherwise MSFS stores the content.xml two
+// levels above the Community folder (e.g. LocalCache\Packages\Community and
+// LocalCache\content.xml) - one level above is checked as well.
+// Returns an empty string if no content.xml is found.
+func findContentXml(root string) string {
+	if path := config.Configuration.ContentXmlFile(); path != "" {
+		return path
+	}
+	parent := filepath.Dir(filepath.Clean(root))
+	for _, dir := range []string{parent, filepath.Dir(parent)} {
+		path := filepath.Join(dir, ContentXmlFile)
+		if exists, _ := util.PathExists(path); exists {
+			return path
+		}
+	}
+	return ""
+}
+
+// markInactivePackages marks all liveries and base aircraft of packages which are
+// disabled in the content.xml of the livery root as not processable.
+func markInactivePackages(root string, liveries []*Livery, aircraft []*Aircraft) {
+	if config.Configuration.IgnoreContentXml() {
+		return
+	}
+	path := findContentXml(root)
+	if path == "" {
+		return
+	}
+	inactive, err := loadInactivePackages(path)
+	if err != nil {
+		log.Printf("Could not read %s: %v", path, err)
+		return
+	}
+	for _, l := range liveries {
+		if inactive[strings.ToLower(l.Package.Name)] {
+			l.PackageInactive = true
+			l.SetReason(ReasonPackageInactive, true)
+		}
+	}
+	for _, a := range aircraft {
+		if inactive[strings.ToLower(a.Package.Name)] {
+			a.PackageInactive = true
+		}
+	}
+}
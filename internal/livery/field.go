@@ -0,0 +1,163 @@
+/*
+ * MatchMaker - create model matching files for VATSIM vPilot
+ *
+ *  MIT License
+ *
+ *  Copyright (c) 2021 Frank Kopp
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NON-INFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ *
+ */
+
+package livery
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Fields are the names of the livery fields which can be used as optional
+// columns and in filters. The names are the aircraft.cfg keys where possible.
+var Fields = []string{
+	"title", "icao_airline", "base_container", "category", "root", "package",
+	"atc_id", "atc_airline", "atc_parking_codes", "atc_parking_types",
+	"ui_type", "ui_variation", "ui_manufacturer", "isAirTraffic", "isUserSelectable",
+	"icao_type_designator", "icao_manufacturer", "icao_model", "remark", "file",
+}
+
+// IsField returns true if name is a known livery field (case insensitive)
+func IsField(name string) bool {
+	return fieldName(name) != ""
+}
+
+// fieldName returns the canonical field name or "" if the field is unknown
+func fieldName(name string) string {
+	for _, f := range Fields {
+		if strings.EqualFold(f, name) {
+			return f
+		}
+	}
+	return ""
+}
+
+// Field returns the value of the named field as string. Unknown fields return "".
+func (l *Livery) Field(name string) string {
+	switch fieldName(name) {
+	case "title":
+		return l.Title
+	case "icao_airline":
+		return l.Icao
+	case "base_container":
+		return l.BaseContainer
+	case "category":
+		return l.Category
+	case "root":
+		return l.Root
+	case "package":
+		return l.Package.String()
+	case "atc_id":
+		return l.AtcId
+	case "atc_airline":
+		return l.AtcAirline
+	case "atc_parking_codes":
+		return l.AtcParkingCodes
+	case "atc_parking_types":
+		return l.AtcParkingTypes
+	case "ui_type":
+		return l.UiType
+	case "ui_variation":
+		return l.UiVariation
+	case "ui_manufacturer":
+		return l.UiManufacturer
+	case "isAirTraffic":
+		return strconv.FormatBool(l.IsAirTraffic)
+	case "isUserSelectable":
+		return strconv.FormatBool(l.IsUserSelectable)
+	case "icao_type_designator":
+		if l.BaseAircraft != nil {
+			return l.BaseAircraft.IcaoTypeDesignator
+		}
+	case "icao_manufacturer":
+		if l.BaseAircraft != nil {
+			return l.BaseAircraft.IcaoManufacturer
+		}
+	case "icao_model":
+		if l.BaseAircraft != nil {
+			return l.BaseAircraft.IcaoModel
+		}
+	case "remark":
+		return l.Remark()
+	case "file":
+		return l.AircraftCfgFile
+	}
+	return ""
+}
+
+// Filter selects liveries by the value of a field. The filter matches if the
+// field contains the value (case insensitive) or - if negated - if it does not.
+type Filter struct {
+	Field  string
+	Value  string
+	Negate bool
+}
+
+// ParseFilter parses a filter expression "field=value" or "field!=value"
+func ParseFilter(expr string) (Filter, error) {
+	negate := false
+	idx := strings.Index(expr, "!=")
+	if idx >= 0 {
+		negate = true
+	} else {
+		idx = strings.Index(expr, "=")
+	}
+	if idx <= 0 {
+		return Filter{}, fmt.Errorf("invalid filter %q - use field=value or field!=value", expr)
+	}
+	field := strings.TrimSpace(expr[:idx])
+	if !IsField(field) {
+		return Filter{}, fmt.Errorf("unknown field %q in filter %q - valid fields are %s", field, expr, strings.Join(Fields, ", "))
+	}
+	value := expr[idx+1:]
+	if negate {
+		value = expr[idx+2:]
+	}
+	return Filter{Field: fieldName(field), Value: strings.TrimSpace(value), Negate: negate}, nil
+}
+
+// Matches returns true if the livery passes the filter
+func (f Filter) Matches(l *Livery) bool {
+	contains := strings.Contains(strings.ToLower(l.Field(f.Field)), strings.ToLower(f.Value))
+	return contains != f.Negate
+}
+
+// FilterLiveries returns the liveries which pass all filters
+func FilterLiveries(liveries []*Livery, filters []Filter) []*Livery {
+	var result []*Livery
+next:
+	for _, l := range liveries {
+		for _, f := range filters {
+			if !f.Matches(l) {
+				continue next
+			}
+		}
+		result = append(result, l)
+	}
+	return result
+}
@@ -0,0 +1,229 @@
+/*
+ * MatchMaker - create model matching files for VATSIM vPilot
+ *
+ *  MIT License
+ *
+ *  Copyright (c) 2021 Frank Kopp
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NON-INFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ *
+ */
+
+package livery
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// IgnoreFile is the name of the files within a livery root which exclude paths
+// from the scan. Each line is a glob pattern relative to the folder of the file.
+// An empty file excludes the whole folder. Lines starting with # are comments.
+const IgnoreFile = ".matchmakerignore"
+
+// globRule is one include or exclude pattern and where it is defined
+type globRule struct {
+	pattern []string // pattern split into path elements, lower case
+	origin  string   // ini setting or ignore file the pattern is defined in - used for the report
+	text    string   // pattern as configured
+}
+
+func newGlobRule(pattern, origin string) globRule {
+	pattern = strings.Trim(strings.ReplaceAll(strings.TrimSpace(pattern), "\\", "/"), "/")
+	return globRule{
+		pattern: strings.Split(strings.ToLower(pattern), "/"),
+		origin:  origin,
+		text:    pattern,
+	}
+}
+
+// String returns the rule as shown in the scan report
+func (r globRule) String() string {
+	return r.origin + ": " + r.text
+}
+
+// matches returns true if the relative path matches the pattern. A pattern with
+// only one element matches the last element of the path (e.g. "backup*").
+// Longer patterns have to match the whole path where "**" matches any number
+// of path elements (e.g. "dev/**/aircraft.cfg").
+func (r globRule) matches(rel []string) bool {
+	if len(r.pattern) == 1 {
+		return len(rel) > 0 && matchElement(r.pattern[0], rel[len(rel)-1])
+	}
+	return matchElements(r.pattern, rel)
+}
+
+// matchesAnyParent returns true if the path or any of its parent folders matches
+func (r globRule) matchesAnyParent(rel []string) bool {
+	for i := len(rel); i > 0; i-- {
+		if r.matches(rel[:i]) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchElements(pattern, rel []string) bool {
+	if len(pattern) == 0 {
+		return len(rel) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(rel); i++ {
+			if matchElements(pattern[1:], rel[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	return len(rel) > 0 && matchElement(pattern[0], rel[0]) && matchElements(pattern[1:], rel[1:])
+}
+
+func matchElement(pattern, name string) bool {
+	matched, err := path.Match(pattern, name)
+	return err == nil && matched
+}
+
+// pathFilter decides which folders and files of a livery root are scanned. It
+// counts the paths pruned by each rule. It is not safe for concurrent use.
+type pathFilter struct {
+	root     string
+	includes []globRule
+	excludes []globRule
+	ignores  map[string][]globRule // rules of the ignore files by folder path
+	pruned   map[string]int        // number of pruned paths by rule
+}
+
+// newPathFilter creates the filter for a livery root with the include and
+// exclude patterns from the configuration
+func newPathFilter(root string, includes, excludes []string) *pathFilter {
+	f := &pathFilter{
+		root:    filepath.Clean(root),
+		ignores: map[string][]globRule{},
+		pruned:  map[string]int{},
+	}
+	for _, p := range includes {
+		if strings.TrimSpace(p) != "" {
+			f.includes = append(f.includes, newGlobRule(p, "include"))
+		}
+	}
+	for _, p := range excludes {
+		if strings.TrimSpace(p) != "" {
+			f.excludes = append(f.excludes, newGlobRule(p, "exclude"))
+		}
+	}
+	return f
+}
+
+// skipDir returns true if the folder and all its content should not be scanned.
+// The ignore file of the folder is read if the folder is scanned.
+func (f *pathFilter) skipDir(dir string) bool {
+	if rel := f.rel(dir); len(rel) > 0 {
+		if rule, found := f.excluded(dir, rel); found {
+			f.pruned[rule.String()]++
+			return true
+		}
+	}
+	rules, all := f.readIgnoreFile(dir)
+	if all && filepath.Clean(dir) != f.root {
+		f.pruned[f.ignoreFileOrigin(dir)+": *"]++
+		return true
+	}
+	if len(rules) > 0 {
+		f.ignores[filepath.Clean(dir)] = rules
+	}
+	return false
+}
+
+// skipFile returns true if the aircraft.cfg file should not be scanned
+func (f *pathFilter) skipFile(file string) bool {
+	rel := f.rel(file)
+	if rule, found := f.excluded(file, rel); found {
+		f.pruned[rule.String()]++
+		return true
+	}
+	if len(f.includes) == 0 {
+		return false
+	}
+	for _, r := range f.includes {
+		if r.matchesAnyParent(rel) {
+			return false
+		}
+	}
+	f.pruned["include: no pattern matches"]++
+	return true
+}
+
+// excluded returns the first exclude rule of the configuration or of an ignore
+// file in one of the parent folders which matches the path
+func (f *pathFilter) excluded(p string, rel []string) (globRule, bool) {
+	for _, r := range f.excludes {
+		if r.matches(rel) {
+			return r, true
+		}
+	}
+	// ignore file patterns are relative to the folder of the ignore file
+	for dir, i := filepath.Dir(p), len(rel)-1; i >= 0; dir, i = filepath.Dir(dir), i-1 {
+		for _, r := range f.ignores[dir] {
+			if r.matches(rel[i:]) {
+				return r, true
+			}
+		}
+	}
+	return globRule{}, false
+}
+
+// readIgnoreFile reads the ignore file of a folder if there is one. all is true
+// if the file exists but has no patterns which means the whole folder is ignored.
+func (f *pathFilter) readIgnoreFile(dir string) (rules []globRule, all bool) {
+	file, err := os.Open(filepath.Join(dir, IgnoreFile))
+	if err != nil {
+		return nil, false
+	}
+	defer file.Close()
+	origin := f.ignoreFileOrigin(dir)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rules = append(rules, newGlobRule(line, origin))
+	}
+	return rules, len(rules) == 0
+}
+
+func (f *pathFilter) ignoreFileOrigin(dir string) string {
+	rel := strings.Join(f.rel(dir), "/")
+	if rel == "" {
+		return IgnoreFile
+	}
+	return rel + "/" + IgnoreFile
+}
+
+// rel returns the path relative to the livery root as lower case path elements
+func (f *pathFilter) rel(p string) []string {
+	rel, err := filepath.Rel(f.root, p)
+	if err != nil || rel == "." {
+		return nil
+	}
+	return strings.Split(strings.ToLower(filepath.ToSlash(rel)), "/")
+}
@@ -0,0 +1,248 @@
+/*
+ * MatchMaker - create model matching files for VATSIM vPilot
+ *
+ *  MIT License
+ *
+ *  Copyright (c) 2021 Frank Kopp
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NON-INFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ *
+ */
+
+package livery
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/frankkopp/MatchMaker/internal/config"
+)
+
+// Severity of a scan issue
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityError
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "INFO"
+	case SeverityWarning:
+		return "WARNING"
+	case SeverityError:
+		return "ERROR"
+	}
+	return "UNKNOWN"
+}
+
+// Category of a scan issue
+type Category int
+
+const (
+	CategoryUnparseable Category = iota
+	CategoryNoFltsim
+	CategoryMissingTitle
+	CategoryMissingIcao
+	CategoryUnknownBaseContainer
+	CategorySyntax
+	CategoryMissingBaseAircraft
+)
+
+func (c Category) String() string {
+	switch c {
+	case CategoryUnparseable:
+		return "unparseable"
+	case CategoryNoFltsim:
+		return "no FLTSIM sections"
+	case CategoryMissingTitle:
+		return "missing title"
+	case CategoryMissingIcao:
+		return "missing icao_airline"
+	case CategoryUnknownBaseContainer:
+		return "unknown base container"
+	case CategorySyntax:
+		return "syntax"
+	case CategoryMissingBaseAircraft:
+		return "base aircraft not installed"
+	}
+	return "unknown"
+}
+
+// Issue is a problem found while scanning the liveries. File is either the path
+// of an aircraft.cfg file or the key of a livery (path and FLTSIM index).
+// Line is the line in the file if the issue belongs to a line, 0 otherwise.
+type Issue struct {
+	File     string
+	Line     int
+	Severity Severity
+	Category Category
+	Message  string
+}
+
+func newIssue(file string, severity Severity, category Category, format string, a ...interface{}) *Issue {
+	return &Issue{
+		File:     file,
+		Severity: severity,
+		Category: category,
+		Message:  fmt.Sprintf(format, a...),
+	}
+}
+
+func (i *Issue) String() string {
+	if i.Line > 0 {
+		return fmt.Sprintf("%-7s %-22s %s(%d): %s", i.Severity, i.Category, i.File, i.Line, i.Message)
+	}
+	return fmt.Sprintf("%-7s %-22s %s: %s", i.Severity, i.Category, i.File, i.Message)
+}
+
+// skipsFile returns true if the issue caused the whole aircraft.cfg file to be skipped
+func (i *Issue) skipsFile() bool {
+	return i.Category == CategoryUnparseable || i.Category == CategoryNoFltsim
+}
+
+// ScanResult is the result of a livery scan. It contains all liveries found and
+// all issues found while scanning.
+type ScanResult struct {
+	Liveries    []*Livery
+	Issues      []*Issue
+	FilesParsed int            // aircraft.cfg files parsed
+	FilesCached int            // aircraft.cfg files taken from the scan cache
+	Pruned      map[string]int // number of folders and files not scanned by include/exclude rule
+	Categories  map[string]int // number of liveries found per SimObject category including unused categories
+	Archived    []*Livery      // liveries available in zip archives but not installed
+	ArchiveGaps []*ArchiveGap  // archives with liveries for ICAO and type codes without installed livery - set with rules.Engine.ArchiveGaps
+}
+
+// SkippedFiles returns the number of aircraft.cfg files which have been skipped
+// completely because of an issue
+func (r *ScanResult) SkippedFiles() int {
+	skipped := 0
+	for _, i := range r.Issues {
+		if i.skipsFile() {
+			skipped++
+		}
+	}
+	return skipped
+}
+
+// IssueCounts returns the number of issues per category
+func (r *ScanResult) IssueCounts() map[Category]int {
+	counts := map[Category]int{}
+	for _, i := range r.Issues {
+		counts[i.Category]++
+	}
+	return counts
+}
+
+// IssueSummary returns one line per issue category with the number of issues
+func (r *ScanResult) IssueSummary() []string {
+	counts := r.IssueCounts()
+	var categories []Category
+	for c := range counts {
+		categories = append(categories, c)
+	}
+	sort.Slice(categories, func(i, j int) bool { return categories[i] < categories[j] })
+	var lines []string
+	for _, c := range categories {
+		lines = append(lines, fmt.Sprintf("%-22s %5d", c.String()+":", counts[c]))
+	}
+	return lines
+}
+
+// PrunedSummary returns one line per include/exclude rule with the number of
+// folders and files the rule excluded from the scan
+func (r *ScanResult) PrunedSummary() []string {
+	var rules []string
+	for rule := range r.Pruned {
+		rules = append(rules, rule)
+	}
+	sort.Strings(rules)
+	var lines []string
+	for _, rule := range rules {
+		lines = append(lines, fmt.Sprintf("%5d %s", r.Pruned[rule], rule))
+	}
+	return lines
+}
+
+// CategorySummary returns one line per SimObject category with the number of
+// liveries found. Categories which are not configured are marked as not used.
+func (r *ScanResult) CategorySummary() []string {
+	var categories []string
+	for c := range r.Categories {
+		categories = append(categories, c)
+	}
+	sort.Strings(categories)
+	var lines []string
+	for _, c := range categories {
+		line := fmt.Sprintf("%-22s %5d", c+":", r.Categories[c])
+		if c != "unknown" && !config.Configuration.IsCategory(c) {
+			line += " (not used)"
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// WriteReport writes a report of the scan to the writer. The detailed report
+// lists each issue, otherwise only the number of issues per category is written.
+func (r *ScanResult) WriteReport(w io.Writer, detailed bool) error {
+	var err error
+	printf := func(format string, a ...interface{}) {
+		if err == nil {
+			_, err = fmt.Fprintf(w, format, a...)
+		}
+	}
+	printf("Scanned %d aircraft.cfg files (%d parsed, %d from cache)\n", r.FilesParsed+r.FilesCached, r.FilesParsed, r.FilesCached)
+	printf("Found %d liveries\n", len(r.Liveries))
+	for _, line := range r.CategorySummary() {
+		printf("  %s\n", line)
+	}
+	printf("Skipped %d files\n", r.SkippedFiles())
+	if len(r.Pruned) > 0 {
+		printf("Paths pruned by include/exclude rules:\n")
+		for _, line := range r.PrunedSummary() {
+			printf("  %s\n", line)
+		}
+	}
+	if len(r.Archived) > 0 {
+		printf("Available in archives but not installed: %d liveries\n", len(r.Archived))
+		for _, gap := range r.ArchiveGaps {
+			printf("  %s: %d liveries for %d ICAO/type codes without installed livery\n", gap.Archive, gap.Liveries, len(gap.Fills))
+			if detailed {
+				printf("    %s\n", strings.Join(gap.Fills, ", "))
+			}
+		}
+	}
+	printf("Issues: %d\n", len(r.Issues))
+	for _, line := range r.IssueSummary() {
+		printf("  %s\n", line)
+	}
+	if detailed {
+		for _, i := range r.Issues {
+			printf("%s\n", i)
+		}
+	}
+	return err
+}
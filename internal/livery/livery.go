@@ -32,27 +32,84 @@ package livery
 
 import (
 	"fmt"
+	"io/ioutil"
 	"log"
+	"path"
 	"path/filepath"
 	"regexp"
 	"strconv"
+	"strings"
 
 	"github.com/frankkopp/MatchMaker/internal/config"
-	"github.com/karrick/godirwalk"
-	"gopkg.in/ini.v1"
 )
 
 // Livery is a data structure for representing liveries meta data as
 // read from an "aircraft.cfg" file. It also keep additional data for managing the
 // the rules generation process. E.g. skipping, custom icao, etc.
 type Livery struct {
-	AircraftCfgFile string
-	BaseContainer   string
-	Title           string
-	Icao            string
-	Custom          bool // has custom config
-	Process         bool // rules should be created
-	Complete        bool // rules should be created
+	AircraftCfgFile   string
+	BaseContainer     string
+	BaseContainerPath string // base_container as given in the aircraft.cfg with "/" as separator
+	Title             string
+	Icao              string
+	Category          string // SimObject category in lower case, e.g. airplane or helicopter - empty if unknown
+
+	// additional FLTSIM meta data
+	AtcId            string
+	AtcAirline       string
+	AtcParkingCodes  string
+	AtcParkingTypes  string
+	UiType           string
+	UiVariation      string
+	UiManufacturer   string
+	IsAirTraffic     bool // MSFS uses the variation for AI traffic (default true)
+	IsUserSelectable bool // the variation can be selected by the user (default true)
+
+	Root            string    `json:"-"` // label of the livery root the livery was found in
+	Package         *Package  `json:"-"` // package the livery belongs to
+	BaseAircraft    *Aircraft `json:"-"` // installed base aircraft - nil if not installed
+	BaseMissing     bool      `json:"-"` // base aircraft is not installed
+	DuplicateOf     string    `json:"-"` // livery which takes precedence over this livery
+	PackageInactive bool      `json:"-"` // package is disabled in MSFS
+	Archive         string    `json:"-"` // zip archive the livery is available in - not installed
+	Custom          bool      `json:"-"` // has custom config
+	Reasons         []Reason  `json:"-"` // why no rules are created for the livery - empty if included
+}
+
+// UserOnly returns true if the variation can only be selected by the user and is not used for AI traffic
+func (l *Livery) UserOnly() bool {
+	return l.IsUserSelectable && !l.IsAirTraffic
+}
+
+// AiOnly returns true if the variation is only used for AI traffic and can't be selected by the user
+func (l *Livery) AiOnly() bool {
+	return l.IsAirTraffic && !l.IsUserSelectable
+}
+
+// Edit applies the process flag, ICAO and weight edited by the user to the
+// livery and its custom data. A changed weight alone keeps the ICAO of the
+// livery. Returns false if nothing has changed.
+func (l *Livery) Edit(process bool, icao string, weight int) bool {
+	custom := config.Configuration.Custom
+	current := config.NoWeight
+	if entry := custom.GetEntry(l.AircraftCfgFile); entry != nil {
+		current = entry.Weight
+	}
+	if weight != current {
+		custom.SetWeight(l.AircraftCfgFile, weight, l.Icao)
+	}
+	if process == !l.HasReason(ReasonDisabledByCustom) && icao == l.Icao {
+		// only the weight has changed or nothing at all
+		return weight != current
+	}
+	if icao != "" {
+		custom.AddOrChangeEntry(l.AircraftCfgFile, process, l.Icao, icao)
+		l.SetIcao(icao)
+		l.SetReason(ReasonDisabledByCustom, !process)
+	} else {
+		l.SetReason(ReasonMissingIcao, true)
+	}
+	return true
 }
 
 // NewLivery creates a new instance of a Livery
@@ -62,102 +119,181 @@ func NewLivery(aircraftCfgFile string) *Livery {
 	}
 }
 
-// ScanLiveryFolder find all aircraft.cfg files as paths with in the start directory
-func ScanLiveryFolder(filePath string) ([]*Livery, error) {
-	// scan liveries
-	var liveries []*Livery
-	err := godirwalk.Walk(filePath, &godirwalk.Options{
-		Callback: func(osPathname string, de *godirwalk.Dirent) error {
-			if de.IsRegular() {
-				if de.Name() != config.FileName {
-					return godirwalk.SkipThis
-				}
-				liveries = append(liveries, processAircraftCfg(osPathname, config.Configuration.Custom)...)
-			}
-			return nil
-		},
-		Unsorted:            true,
-		FollowSymbolicLinks: true,
-	})
-	return liveries, err
-}
-
 // parse the file and try to find the three relevant data points:
 // base = base plane model
 // icao = airline code
 // name = title of the variation
-// returns nil if file was invalid or not a livery aircraft.cfg
-func processAircraftCfg(path string, custom *config.CustomData) []*Livery {
-
-	// this is to catch bad aircraft.cfg files which cause the ini library to throw a panic
-	defer func() {
-		if err := recover(); err != nil {
-			log.Println(err)
-		}
-	}()
-
-	// load the aircraft.cfg file as if it were a ini file
-	cfg, err := ini.InsensitiveLoad(path)
+// returns nil if file was invalid or not a livery aircraft.cfg together with an
+// issue describing why the file was skipped. Problems the parser could correct are
+// returned as issues as well.
+// If the file is a plane it is returned as base aircraft for other liveries as well.
+// The liveries are returned as read from the file - custom data is not yet applied.
+func processAircraftCfg(path string) ([]*Livery, *Aircraft, []*Issue) {
+	data, err := ioutil.ReadFile(path)
 	if err != nil {
-		return nil
+		return nil, nil, []*Issue{newIssue(path, SeverityError, CategoryUnparseable, "%v", err)}
 	}
+	return processAircraftCfgData(path, data)
+}
 
-	isPlane := cfg.Section("GENERAL").Key("Category").String() == "airplane"
-	isVariation := cfg.Section("VARIATION").HasKey("base_container")
+// processAircraftCfgData parses the content of an aircraft.cfg file. path is
+// used as key for the liveries and to name the base aircraft. See processAircraftCfg.
+func processAircraftCfgData(path string, data []byte) ([]*Livery, *Aircraft, []*Issue) {
+	// parse the aircraft.cfg file with the lenient cfg parser and report what had to be corrected
+	cfg, problems := parseAircraftCfg(data)
+	var issues []*Issue
+	for _, p := range problems {
+		issue := newIssue(path, SeverityWarning, CategorySyntax, "%s", p.message)
+		issue.Line = p.line
+		issues = append(issues, issue)
+	}
+
+	// base models of all SimObject categories are parsed - the configured categories
+	// are applied after the cache so the cache does not depend on the configuration
+	// liveries often copy the [GENERAL] section of their base aircraft - only a
+	// SimObject without base container is a base aircraft
+	category := strings.ToLower(cfg.section("GENERAL").get("category"))
+	isVariation := cfg.section("VARIATION").has("base_container")
+	isBase := category != "" && !isVariation
 
 	// Liveries always have a base container. We skip other files
-	if !isPlane && !isVariation {
+	if !isBase && !isVariation {
 		if *config.Configuration.Verbose {
-			fmt.Printf("Not a plane or livery: %s\n", path)
+			fmt.Printf("Not a base model or livery: %s\n", path)
 		}
-		return nil
+		return nil, nil, nil
 	}
 
-	var baseContainer string
+	var baseContainer, baseContainerPath string
+	var aircraft *Aircraft
 	if isVariation {
-		baseContainer = getBaseName(cfg.Section("VARIATION").Key("base_container").Value())
-		// fmt.Printf("BASE %s\n", baseContainer)
-	} else { // is plane
+		baseContainerPath = strings.ReplaceAll(cfg.section("VARIATION").get("base_container"), "\\", "/")
+		baseContainer = getBaseName(baseContainerPath)
+		if category == "" {
+			category = categoryFromPath(path)
+		}
+	} else { // is base model
 		baseContainer = filepath.Base(filepath.Dir(path))
-		// fmt.Printf("PLANE %s\n", baseContainer)
+		aircraft = newAircraft(path, cfg.section("GENERAL"))
 	}
 
+	// all FLTSIM.N sections independent of gaps in the numbering
 	var liveries []*Livery
-	for index := 0; cfg.Section("FLTSIM." + strconv.Itoa(index)).HasKey("title"); index++ {
-		// fmt.Printf("FLTSIM %d: %s\n", index, cfg.Section("FLTSIM."+strconv.Itoa(index)).Key("title").String())
-
+	for _, fltsim := range cfg.fltsimSections() {
 		// generate a unique key from path and index
-		variationKey := getVariationKey(path, index)
+		variationKey := getVariationKey(path, fltsim.index)
 
 		// create the Livery instance from the aircraft.cfg data
 		livery := NewLivery(variationKey)
 		livery.BaseContainer = baseContainer
-		livery.Title = cleanUp(cfg.Section("FLTSIM." + strconv.Itoa(index)).Key("title").String())
-		livery.Icao = cleanUp(cfg.Section("FLTSIM." + strconv.Itoa(index)).Key("icao_airline").String())
-		livery.Complete = livery.Title != "" && livery.Icao != ""
-		livery.Process = livery.Complete && config.Configuration.Ini.Section("defaultTypes").HasKey(livery.BaseContainer)
-
-		// check for custom data and overwrite livery data if necessary
-		if custom.HasEntry(variationKey) {
-			if *config.Configuration.Verbose {
-				fmt.Println("Custom data applied for ", variationKey)
-			}
-			entry := custom.GetEntry(variationKey)
-			if entry.CustomIcao != "" {
-				livery.Icao = entry.CustomIcao
-				livery.Complete = true
-				livery.Custom = true
-			}
-			// to be able to be processed the livery data has to be complete e.g. has an ICAO
-			livery.Process = entry.Process && livery.Complete && config.Configuration.Ini.Section("defaultTypes").HasKey(livery.BaseContainer)
-		}
+		livery.BaseContainerPath = baseContainerPath
+		livery.Category = category
+		livery.Title = fltsim.section.get("title")
+		livery.Icao = fltsim.section.get("icao_airline")
+		livery.AtcId = fltsim.section.get("atc_id")
+		livery.AtcAirline = fltsim.section.get("atc_airline")
+		livery.AtcParkingCodes = fltsim.section.get("atc_parking_codes")
+		livery.AtcParkingTypes = fltsim.section.get("atc_parking_types")
+		livery.UiType = fltsim.section.get("ui_type")
+		livery.UiVariation = fltsim.section.get("ui_variation")
+		livery.UiManufacturer = fltsim.section.get("ui_manufacturer")
+		livery.IsAirTraffic = fltsim.section.getBool("isAirTraffic", true)
+		livery.IsUserSelectable = fltsim.section.getBool("isUserSelectable", true)
 
 		// add to list
 		liveries = append(liveries, livery)
 	}
 
+	if len(liveries) == 0 {
+		return nil, aircraft, append(issues, newIssue(path, SeverityWarning, CategoryNoFltsim, "no FLTSIM sections"))
+	}
+
 	// returns nil if file was not a belonging to a livery or new Livery instance otherwise
-	return liveries
+	return liveries, aircraft, issues
+}
+
+// applyCustomData determines if the livery can be processed and applies the
+// custom data for the livery if there is an entry for it.
+func applyCustomData(livery *Livery, custom *config.CustomData) {
+	livery.SetReason(ReasonMissingTitle, livery.Title == "")
+	livery.SetReason(ReasonBaseNotConfigured, !config.Configuration.Ini.Section("defaultTypes").HasKey(livery.BaseContainer))
+
+	// check for custom data and overwrite livery data if necessary
+	entry := custom.GetEntry(livery.AircraftCfgFile)
+	if entry != nil && *config.Configuration.Verbose {
+		fmt.Println("Custom data applied for ", livery.AircraftCfgFile)
+	}
+	livery.applyCustomEntry(entry)
+}
+
+// applyCustomEntry applies the ICAO of the custom data entry and sets the
+// reasons which depend on the custom data - entry is nil if there is none
+func (l *Livery) applyCustomEntry(entry *config.Entry) {
+	l.Custom = entry != nil
+	if entry != nil && entry.CustomIcao != "" {
+		l.SetIcao(entry.CustomIcao)
+	}
+	l.SetReason(ReasonMissingIcao, l.Icao == "")
+	l.SetReason(ReasonDisabledByCustom, entry != nil && !entry.Process)
+}
+
+// RemoveCustomData restores the original ICAO of the livery and the reasons it
+// has without custom data
+func (l *Livery) RemoveCustomData(originalIcao string) {
+	l.Icao = originalIcao
+	l.applyCustomEntry(nil)
+}
+
+// applyVariationFilter excludes user only and AI only variations if configured
+func applyVariationFilter(livery *Livery) {
+	livery.SetReason(ReasonUserOnly, livery.UserOnly() && !config.Configuration.IncludeUserOnly())
+	livery.SetReason(ReasonAiOnly, livery.AiOnly() && !config.Configuration.IncludeAiOnly())
+}
+
+// checkLivery returns the issues which prevent the livery from being used for
+// rules. Custom data has to be applied before as it might complete the livery.
+func checkLivery(livery *Livery) []*Issue {
+	var issues []*Issue
+	if livery.Title == "" {
+		issues = append(issues, newIssue(livery.AircraftCfgFile, SeverityWarning, CategoryMissingTitle,
+			"FLTSIM section has no title"))
+	}
+	if livery.Icao == "" {
+		issues = append(issues, newIssue(livery.AircraftCfgFile, SeverityWarning, CategoryMissingIcao,
+			"%s has no icao_airline - add a custom ICAO to use it", livery.Title))
+	}
+	if livery.BaseContainer == "" {
+		issues = append(issues, newIssue(livery.AircraftCfgFile, SeverityWarning, CategoryUnknownBaseContainer,
+			"%s has no base_container", livery.Title))
+	} else if !config.Configuration.Ini.Section("defaultTypes").HasKey(livery.BaseContainer) {
+		issues = append(issues, newIssue(livery.AircraftCfgFile, SeverityInfo, CategoryUnknownBaseContainer,
+			"base container %s of %s is not configured in [defaultTypes]", livery.BaseContainer, livery.Title))
+	}
+	return issues
+}
+
+// simObjectFolderCategories maps the folders below SimObjects to the category of
+// the SimObjects within them if the name is not just the plural of the category
+var simObjectFolderCategories = map[string]string{
+	"airplanes":  "airplane",
+	"rotorcraft": "helicopter",
+}
+
+// categoryFromPath returns the SimObject category from the folder below the
+// SimObjects folder of the path, e.g. SimObjects\Rotorcraft\... is a helicopter.
+// Returns an empty string if the path has no SimObjects folder.
+func categoryFromPath(path string) string {
+	elements := strings.Split(strings.ReplaceAll(path, "\\", "/"), "/")
+	for i := 0; i < len(elements)-1; i++ {
+		if strings.EqualFold(elements[i], "SimObjects") {
+			folder := strings.ToLower(elements[i+1])
+			if category, found := simObjectFolderCategories[folder]; found {
+				return category
+			}
+			return strings.TrimSuffix(folder, "s")
+		}
+	}
+	return ""
 }
 
 func getVariationKey(path string, index int) string {
@@ -175,8 +311,9 @@ func cleanUp(value string) string {
 }
 
 // extract the base container name from the aircraft.cfg base_container value as this is often a relative path
+// MSFS uses "\" as well as "/" as separator independent of the OS
 func getBaseName(line string) string {
-	filePath := filepath.Base(line)
+	filePath := path.Base(strings.ReplaceAll(line, "\\", "/"))
 	if len(filePath) <= 1 {
 		return ""
 	}
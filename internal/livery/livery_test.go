@@ -28,9 +28,27 @@
 package livery
 
 import (
+	"archive/zip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/frankkopp/MatchMaker/internal/config"
 )
 
+// setupConfig loads the given ini into the configuration used by the scan
+func setupConfig(t *testing.T, iniString string) {
+	verbose, rescan := false, true
+	config.Configuration.Verbose = &verbose
+	config.Configuration.Rescan = &rescan
+	if err := config.Configuration.LoadFromString(iniString); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func Test_cleanUp(t *testing.T) {
 	type args struct {
 		value string
@@ -54,3 +72,495 @@ func Test_cleanUp(t *testing.T) {
 		})
 	}
 }
+
+func TestScanContentXml(t *testing.T) {
+	community := filepath.Join("testdata", "msfs", "Packages", "Community")
+	tests := []struct {
+		name         string
+		ignore       bool
+		wantProcess  map[string]bool
+		wantInactive map[string]bool
+	}{
+		{"content.xml", false,
+			map[string]bool{"DLH": true, "BAW": false},
+			map[string]bool{"DLH": false, "BAW": true}},
+		{"ignore content.xml", true,
+			map[string]bool{"DLH": true, "BAW": true},
+			map[string]bool{"DLH": false, "BAW": false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupConfig(t, "[scan]\ncacheFile =\nexcludeMissingBase = false\n[defaultTypes]\nAsobo_A320_NEO = Airbus A320 Neo Asobo\n")
+			config.Configuration.SetIgnoreContentXml(tt.ignore)
+			result, err := ScanLiveryFolder(community)
+			if err != nil {
+				t.Fatal(err)
+			}
+			liveries := result.Liveries
+			if len(liveries) != 2 {
+				t.Fatalf("ScanLiveryFolder() found %d liveries, want 2", len(liveries))
+			}
+			for _, l := range liveries {
+				if l.Included() != tt.wantProcess[l.Icao] {
+					t.Errorf("%s: Included = %v, want %v", l.Icao, l.Included(), tt.wantProcess[l.Icao])
+				}
+				if l.PackageInactive != tt.wantInactive[l.Icao] {
+					t.Errorf("%s: PackageInactive = %v, want %v", l.Icao, l.PackageInactive, tt.wantInactive[l.Icao])
+				}
+				if l.PackageInactive != l.HasReason(ReasonPackageInactive) || (l.PackageInactive && l.Remark() == "") {
+					t.Errorf("%s: no remark for inactive package", l.Icao)
+				}
+			}
+		})
+	}
+}
+
+func TestScanPackages(t *testing.T) {
+	community := filepath.Join("testdata", "msfs", "Packages", "Community")
+	setupConfig(t, "[scan]\ncacheFile =\nignoreContentXml = true\n")
+	result, err := ScanLiveryFolder(community)
+	if err != nil {
+		t.Fatal(err)
+	}
+	packages := map[string]*Package{}
+	for _, l := range result.Liveries {
+		if l.Package == nil {
+			t.Fatalf("%s: no package", l.AircraftCfgFile)
+		}
+		packages[l.Icao] = l.Package
+	}
+	dlh := packages["DLH"]
+	if dlh.Name != "active-livery-pack" || !dlh.HasManifest || dlh.Title != "Lufthansa A320neo Livery" ||
+		dlh.Creator != "MatchMaker Test" || dlh.PackageVersion != "1.2.0" || dlh.ContentType != "AIRCRAFT" ||
+		len(dlh.Dependencies) != 1 || dlh.Files != 1 || dlh.Size != 125 {
+		t.Errorf("package from manifest.json not as expected: %+v", dlh)
+	}
+	baw := packages["BAW"]
+	if baw.Name != "disabled-livery-pack" || baw.HasManifest || baw.String() != "disabled-livery-pack" {
+		t.Errorf("package without manifest.json not as expected: %+v", baw)
+	}
+}
+
+func TestScanIssues(t *testing.T) {
+	setupConfig(t, "[scan]\ncacheFile =\n[defaultTypes]\nAsobo_A320_NEO = Airbus A320 Neo Asobo\n")
+	result, err := ScanLiveryFolder(filepath.Join("testdata", "issues"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Liveries) != 1 {
+		t.Errorf("found %d liveries, want 1", len(result.Liveries))
+	}
+	if result.SkippedFiles() != 1 {
+		t.Errorf("SkippedFiles() = %d, want 1", result.SkippedFiles())
+	}
+	want := map[Category]int{
+		CategoryNoFltsim:             1,
+		CategoryMissingIcao:          1,
+		CategoryUnknownBaseContainer: 1,
+	}
+	counts := result.IssueCounts()
+	for c, n := range want {
+		if counts[c] != n {
+			t.Errorf("%d issues of category %s, want %d", counts[c], c, n)
+		}
+	}
+}
+
+func TestScanVariationMetaData(t *testing.T) {
+	setupConfig(t, "[scan]\ncacheFile =\nincludeUserOnly = false\nexcludeMissingBase = false\n[defaultTypes]\nAsobo_A320_NEO = Airbus A320 Neo Lufthansa\n")
+	result, err := ScanLiveryFolder(filepath.Join("testdata", "variations"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Liveries) != 3 {
+		t.Fatalf("found %d liveries, want 3", len(result.Liveries))
+	}
+	l := result.Liveries[0]
+	if l.AtcId != "D-AINA" || l.AtcAirline != "Lufthansa" || l.AtcParkingTypes != "GATE,RAMP" || l.AtcParkingCodes != "DLH" ||
+		l.UiType != "A320neo" || l.UiVariation != "Lufthansa D-AINA" || l.UiManufacturer != "Airbus" {
+		t.Errorf("FLTSIM meta data not parsed: %+v", l)
+	}
+	if !l.IsAirTraffic || !l.IsUserSelectable || !l.Included() {
+		t.Errorf("%s: IsAirTraffic=%v IsUserSelectable=%v Included=%v, want all true", l.Title, l.IsAirTraffic, l.IsUserSelectable, l.Included())
+	}
+	if userOnly := result.Liveries[1]; !userOnly.UserOnly() || !userOnly.HasReason(ReasonUserOnly) || !userOnly.Blocked() {
+		t.Errorf("%s: UserOnly=%v Reasons=%v Status=%v, want excluded", userOnly.Title, userOnly.UserOnly(), userOnly.Reasons, userOnly.Status())
+	}
+	if aiOnly := result.Liveries[2]; !aiOnly.AiOnly() || !aiOnly.Included() {
+		t.Errorf("%s: AiOnly=%v Reasons=%v, want included", aiOnly.Title, aiOnly.AiOnly(), aiOnly.Reasons)
+	}
+
+	filter, err := ParseFilter("isAirTraffic!=false")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(FilterLiveries(result.Liveries, []Filter{filter})); n != 2 {
+		t.Errorf("filter %+v matches %d liveries, want 2", filter, n)
+	}
+	filter, _ = ParseFilter("ui_variation=d-aina")
+	if n := len(FilterLiveries(result.Liveries, []Filter{filter})); n != 1 {
+		t.Errorf("filter %+v matches %d liveries, want 1", filter, n)
+	}
+	if _, err := ParseFilter("unknown=x"); err == nil {
+		t.Error("ParseFilter() accepted an unknown field")
+	}
+}
+
+func TestScanBaseAircraft(t *testing.T) {
+	setupConfig(t, "[scan]\ncacheFile =\nignoreContentXml = true\nexcludeMissingBase = true\n[defaultTypes]\nAsobo_A320_NEO = Airbus A320 Neo Asobo\n")
+	roots := []config.LiveryRoot{
+		{Label: "Community", Path: filepath.Join("testdata", "msfs", "Packages", "Community"), Enabled: true},
+		{Label: "Official", Path: filepath.Join("testdata", "msfs", "Official", "OneStore"), Enabled: true},
+	}
+	result, err := ScanLiveryRoots(roots)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Liveries) != 4 {
+		t.Fatalf("found %d liveries, want 4", len(result.Liveries))
+	}
+	for _, l := range result.Liveries {
+		switch l.BaseContainer {
+		case "Asobo_A320_NEO":
+			a := l.BaseAircraft
+			if a == nil || a.Root != "Official" || a.IcaoTypeDesignator != "A20N" || a.IcaoManufacturer != "AIRBUS" || a.IcaoModel != "A-320neo" {
+				t.Errorf("%s: base aircraft not resolved: %+v", l.Title, a)
+			}
+			// the base aircraft itself has no icao_airline and is not processed
+			if l.BaseMissing || l.Included() != (l.Icao != "") {
+				t.Errorf("%s: BaseMissing=%v Reasons=%v", l.Title, l.BaseMissing, l.Reasons)
+			}
+		case "Asobo_B787_10":
+			if l.BaseAircraft != nil || !l.BaseMissing || !l.HasReason(ReasonBaseMissing) || l.Remark() == "" {
+				t.Errorf("%s: BaseMissing=%v Reasons=%v, want missing base excluded", l.Title, l.BaseMissing, l.Reasons)
+			}
+		default:
+			t.Errorf("%s: unexpected base container %s", l.Title, l.BaseContainer)
+		}
+	}
+	if n := result.IssueCounts()[CategoryMissingBaseAircraft]; n != 1 {
+		t.Errorf("%d issues of category %s, want 1", n, CategoryMissingBaseAircraft)
+	}
+}
+
+func Test_processAircraftCfgData(t *testing.T) {
+	setupConfig(t, "[scan]\ncacheFile =\n")
+	general := "[GENERAL]\ncategory = \"airplane\"\nicao_type_designator = \"A20N\"\n"
+	fltsim := "[FLTSIM.0]\ntitle = \"A320 Lufthansa\"\nicao_airline = \"DLH\"\n"
+	tests := []struct {
+		name          string
+		path          string
+		data          string
+		baseContainer string
+		base          bool
+	}{
+		{"base aircraft", "pack/SimObjects/Airplanes/Asobo_A320_NEO/aircraft.cfg", general + fltsim, "Asobo_A320_NEO", true},
+		{"livery", "pack/SimObjects/Airplanes/Asobo_A320_NEO-DLH/aircraft.cfg", "[VARIATION]\nbase_container = \"..\\Asobo_A320_NEO\"\n" + fltsim, "Asobo_A320_NEO", false},
+		// liveries often copy the [GENERAL] section of the base aircraft
+		{"livery with GENERAL", "pack/SimObjects/Airplanes/Asobo_A320_NEO-DLH/aircraft.cfg", "[VARIATION]\nbase_container = \"..\\Asobo_A320_NEO\"\n" + general + fltsim, "Asobo_A320_NEO", false},
+	}
+	for _, tt := range tests {
+		liveries, aircraft, _ := processAircraftCfgData(tt.path, []byte(tt.data))
+		if (aircraft != nil) != tt.base {
+			t.Errorf("%s: base aircraft = %+v, want base aircraft %v", tt.name, aircraft, tt.base)
+		}
+		if len(liveries) != 1 || liveries[0].BaseContainer != tt.baseContainer || liveries[0].Category != "airplane" {
+			t.Errorf("%s: liveries = %+v", tt.name, liveries)
+		}
+	}
+}
+
+func TestScanDefaultConfigMissingBase(t *testing.T) {
+	// the default configuration without ini file
+	dir, err := ioutil.TempDir("", "defaultini")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	verbose, rescan, iniFile := false, true, filepath.Join(dir, "missing.ini")
+	config.Configuration.Verbose = &verbose
+	config.Configuration.Rescan = &rescan
+	config.Configuration.IniFileName = &iniFile
+	config.Configuration.LoadIni()
+	config.Configuration.Ini.Section("scan").Key("cacheFile").SetValue("")
+	config.Configuration.Ini.Section("scan").Key("ignoreContentXml").SetValue("true")
+
+	community := config.LiveryRoot{Label: "Community", Path: filepath.Join("testdata", "msfs", "Packages", "Community"), Enabled: true}
+	official := config.LiveryRoot{Label: "Official", Path: filepath.Join("testdata", "msfs", "Official", "OneStore"), Enabled: true}
+	tests := []struct {
+		name     string
+		roots    []config.LiveryRoot
+		excluded bool
+		issues   int
+	}{
+		// without the folder of the base aircraft the liveries are excluded
+		{"Community only", []config.LiveryRoot{community}, true, 2},
+		// only the B787 livery of the Official folder has no base aircraft
+		{"Community and Official", []config.LiveryRoot{community, official}, false, 1},
+	}
+	for _, tt := range tests {
+		result, err := ScanLiveryRoots(tt.roots)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, l := range result.Liveries {
+			if l.Root != "Community" {
+				continue
+			}
+			if l.BaseMissing != tt.excluded || l.HasReason(ReasonBaseMissing) != tt.excluded || l.Included() == tt.excluded {
+				t.Errorf("%s: %s BaseMissing=%v Reasons=%v, want excluded %v", tt.name, l.Title, l.BaseMissing, l.Reasons, tt.excluded)
+			}
+		}
+		if n := result.IssueCounts()[CategoryMissingBaseAircraft]; n != tt.issues {
+			t.Errorf("%s: %d missing base aircraft reported, want %d", tt.name, n, tt.issues)
+		}
+	}
+}
+
+func TestScanIncludeExclude(t *testing.T) {
+	tests := []struct {
+		name       string
+		ini        string
+		wantIcao   []string
+		wantPruned map[string]int
+	}{
+		{"exclude and ignore files", "exclude = *-backup\n", []string{"AAA", "BBB"},
+			map[string]int{"exclude: *-backup": 1, "dev/.matchmakerignore: *": 1, "pack-b/.matchmakerignore: old": 1}},
+		{"include", "include = pack-b/**\n", []string{"BBB"},
+			map[string]int{"include: no pattern matches": 2, "dev/.matchmakerignore: *": 1, "pack-b/.matchmakerignore: old": 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupConfig(t, "[scan]\ncacheFile =\nexcludeMissingBase = false\n"+tt.ini)
+			result, err := ScanLiveryFolder(filepath.Join("testdata", "ignore"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var icao []string
+			for _, l := range result.Liveries {
+				icao = append(icao, l.Icao)
+			}
+			if !reflect.DeepEqual(icao, tt.wantIcao) {
+				t.Errorf("found liveries %v, want %v", icao, tt.wantIcao)
+			}
+			if !reflect.DeepEqual(result.Pruned, tt.wantPruned) {
+				t.Errorf("Pruned = %v, want %v", result.Pruned, tt.wantPruned)
+			}
+		})
+	}
+}
+
+func TestScanArchives(t *testing.T) {
+	archiveDir, err := ioutil.TempDir("", "archives")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(archiveDir)
+	writeZip(t, filepath.Join(archiveDir, "a320-pack.zip"), map[string]string{
+		"a320-pack/SimObjects/Airplanes/Asobo_A320_NEO-BAW/aircraft.cfg": "[VARIATION]\nbase_container = \"..\\Asobo_A320_NEO\"\n" +
+			"[FLTSIM.0]\ntitle = \"Airbus A320 Neo British Airways\"\nicao_airline = \"BAW\"\n",
+		"a320-pack/SimObjects/Airplanes/Asobo_A320_NEO-DLH2/aircraft.cfg": "[VARIATION]\nbase_container = \"..\\Asobo_A320_NEO\"\n" +
+			"[FLTSIM.0]\ntitle = \"Airbus A320 Neo Lufthansa 2\"\nicao_airline = \"DLH\"\n",
+		"a320-pack/SimObjects/Airplanes/Asobo_A320_NEO-EZY/aircraft.cfg": "[VARIATION]\nbase_container = \"..\\Asobo_A320_NEO\"\n" +
+			"[FLTSIM.0]\ntitle = \"Airbus A320 Neo easyJet AI\"\nicao_airline = \"EZY\"\nisUserSelectable = 0\n",
+	})
+
+	setupConfig(t, "[scan]\ncacheFile =\nincludeAiOnly = false\narchiveDirs = "+archiveDir+"\n"+
+		"[defaultTypes]\nAsobo_A320_NEO = Airbus A320 Neo Asobo\n[typeVariations]\nAsobo_A320_NEO = A20N,A320\n")
+	roots := []config.LiveryRoot{
+		{Label: "Community", Path: filepath.Join("testdata", "msfs", "Packages", "Community"), Enabled: true},
+		{Label: "Official", Path: filepath.Join("testdata", "msfs", "Official", "OneStore"), Enabled: true},
+	}
+	result, err := ScanLiveryRoots(roots)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Archived) != 3 {
+		t.Fatalf("found %d archived liveries, want 3", len(result.Archived))
+	}
+	for _, l := range result.Archived {
+		if r, _ := l.Reason(); r != ReasonArchived || !l.Blocked() || l.BaseAircraft == nil || l.Remark() == "" {
+			t.Errorf("%s: Reasons=%v Blocked=%v BaseAircraft=%v Remark=%q", l.Title, l.Reasons, l.Blocked(), l.BaseAircraft, l.Remark())
+		}
+		// the variation filter applies to archived liveries as well
+		if l.Installable() != (l.Icao != "EZY") {
+			t.Errorf("%s: Installable = %v with reasons %v", l.Title, l.Installable(), l.Reasons)
+		}
+	}
+}
+
+// writeZip creates a zip archive with the given files and content
+func writeZip(t *testing.T, path string, files map[string]string) {
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w := zip.NewWriter(f)
+	for name, content := range files {
+		fw, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeFiles creates the given files with their content below the directory
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestScanCategories(t *testing.T) {
+	tests := []struct {
+		name       string
+		categories string
+		want       int
+	}{
+		{"default", "", 2},
+		{"airplanes only", "categories = airplane\n", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupConfig(t, "[scan]\ncacheFile =\n"+tt.categories+
+				"[defaultTypes]\nAsobo_H135 = Airbus H135 Asobo\n[helicopterTypeVariations]\nAsobo_H135 = EC35,EC45\n")
+			result, err := ScanLiveryFolder(filepath.Join("testdata", "categories"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(result.Liveries) != tt.want {
+				t.Fatalf("found %d liveries, want %d", len(result.Liveries), tt.want)
+			}
+			if want := map[string]int{"helicopter": 2, "boat": 1}; !reflect.DeepEqual(result.Categories, want) {
+				t.Errorf("Categories = %v, want %v", result.Categories, want)
+			}
+			for _, l := range result.Liveries {
+				if l.Category != "helicopter" || l.BaseAircraft == nil {
+					t.Errorf("%s: Category = %q, BaseAircraft = %v", l.Title, l.Category, l.BaseAircraft)
+				}
+				if l.Icao != "" && !l.Included() {
+					t.Errorf("%s: Reasons = %v", l.Title, l.Reasons)
+				}
+			}
+			// SimObjects of categories which are not used are not reported
+			for _, i := range result.Issues {
+				if strings.Contains(i.File, "Boats") {
+					t.Errorf("unexpected issue %s", i)
+				}
+			}
+		})
+	}
+}
+
+func Test_categoryFromPath(t *testing.T) {
+	tests := map[string]string{
+		`D:\Community\pack\SimObjects\Airplanes\A320-DLH\aircraft.cfg`: "airplane",
+		"pack/SimObjects/Rotorcraft/H135-ADAC/aircraft.cfg":            "helicopter",
+		"pack/simobjects/Boats/Ferry/aircraft.cfg":                     "boat",
+		"pack/A320-DLH/aircraft.cfg":                                   "",
+	}
+	for path, want := range tests {
+		if got := categoryFromPath(path); got != want {
+			t.Errorf("categoryFromPath(%s) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestLiveryEdit(t *testing.T) {
+	setupConfig(t, "[scan]\ncacheFile =\n[defaultTypes]\nAsobo_A320_NEO = Airbus A320 Neo Asobo\n"+
+		"[customData]\n-- end of customData - do not delete --\n")
+	custom := config.Configuration.Custom
+	l := &Livery{AircraftCfgFile: "dlh.cfg", Title: "A", Icao: "DLH", BaseContainer: "Asobo_A320_NEO"}
+
+	if l.Edit(true, "DLH", config.NoWeight) || custom.HasEntry("dlh.cfg") {
+		t.Errorf("Edit() without changes changed the custom data: %v", custom.GetEntry("dlh.cfg"))
+	}
+	// a weight only edit keeps the ICAO of the livery
+	if !l.Edit(true, "DLH", 3) {
+		t.Errorf("Edit() of the weight = false, want true")
+	}
+	if e := custom.GetEntry("dlh.cfg"); e == nil || e.CustomIcao != "" || !e.Process || e.Weight != 3 || l.Icao != "DLH" || l.Status() != StatusIncluded {
+		t.Errorf("Edit() of the weight: entry = %v, Icao = %s, Status = %v", e, l.Icao, l.Status())
+	}
+	// a custom ICAO keeps the weight
+	if !l.Edit(false, "CLH", 3) {
+		t.Errorf("Edit() of the ICAO = false, want true")
+	}
+	if e := custom.GetEntry("dlh.cfg"); e == nil || e.CustomIcao != "CLH" || e.Process || e.Weight != 3 || l.Icao != "CLH" || l.Status() != StatusDisabled {
+		t.Errorf("Edit() of the ICAO: entry = %v, Icao = %s, Status = %v", e, l.Icao, l.Status())
+	}
+}
+
+func TestLiveryReasons(t *testing.T) {
+	setupConfig(t, "[scan]\ncacheFile =\nincludeUserOnly = false\n[defaultTypes]\nAsobo_A320_NEO = Airbus A320 Neo Asobo\n"+
+		"[customData]\ndisabled.cfg,false,,\ncustom.cfg,true,,DLH\n-- end of customData - do not delete --\n")
+	tests := []struct {
+		livery *Livery
+		want   []Reason
+		status Status
+	}{
+		{&Livery{AircraftCfgFile: "ok.cfg", Title: "A", Icao: "DLH", BaseContainer: "Asobo_A320_NEO"}, nil, StatusIncluded},
+		{&Livery{AircraftCfgFile: "noicao.cfg", Title: "B", BaseContainer: "Asobo_A320_NEO"}, []Reason{ReasonMissingIcao}, StatusIncomplete},
+		{&Livery{AircraftCfgFile: "custom.cfg", Title: "C", BaseContainer: "Asobo_A320_NEO"}, nil, StatusIncluded},
+		{&Livery{AircraftCfgFile: "disabled.cfg", Title: "D", Icao: "DLH", BaseContainer: "Asobo_A320_NEO"}, []Reason{ReasonDisabledByCustom}, StatusDisabled},
+		{&Livery{AircraftCfgFile: "base.cfg", Icao: "DLH", BaseContainer: "Asobo_B787_10"}, []Reason{ReasonMissingTitle, ReasonBaseNotConfigured}, StatusIncomplete},
+		{&Livery{AircraftCfgFile: "user.cfg", Title: "E", BaseContainer: "Asobo_A320_NEO", IsUserSelectable: true}, []Reason{ReasonUserOnly, ReasonMissingIcao}, StatusBlocked},
+	}
+	for _, tt := range tests {
+		tt.livery.IsAirTraffic = !tt.livery.IsUserSelectable
+		applyCustomData(tt.livery, config.Configuration.Custom)
+		applyVariationFilter(tt.livery)
+		if !reflect.DeepEqual(tt.livery.Reasons, tt.want) || tt.livery.Status() != tt.status {
+			t.Errorf("%s: Reasons = %v, Status = %v, want %v, %v", tt.livery.AircraftCfgFile, tt.livery.Reasons, tt.livery.Status(), tt.want, tt.status)
+		}
+	}
+
+	// only included liveries and liveries disabled by custom data can be toggled
+	for _, tt := range tests {
+		want := tt.status == StatusIncluded || tt.status == StatusDisabled
+		if got := tt.livery.Toggleable(); got != want {
+			t.Errorf("%s: Toggleable() = %v, want %v", tt.livery.AircraftCfgFile, got, want)
+		}
+	}
+
+	// a custom ICAO completes the livery and the user can disable it again
+	l := tests[1].livery
+	l.SetIcao("DLH")
+	l.SetReason(ReasonDisabledByCustom, true)
+	if r, found := l.Reason(); !found || r != ReasonDisabledByCustom || l.Status() != StatusDisabled || !l.Complete() {
+		t.Errorf("Reasons = %v, Status = %v after custom ICAO", l.Reasons, l.Status())
+	}
+
+	liveries := make([]*Livery, len(tests))
+	for i, tt := range tests {
+		liveries[i] = tt.livery
+	}
+	want := []ReasonCount{{ReasonUserOnly, 1}, {ReasonMissingTitle, 1}, {ReasonMissingIcao, 1}, {ReasonBaseNotConfigured, 1}, {ReasonDisabledByCustom, 2}}
+	if got := CountReasons(liveries); !reflect.DeepEqual(got, want) {
+		t.Errorf("CountReasons() = %v, want %v", got, want)
+	}
+
+	// removing the custom data restores the original ICAO and the reasons without custom data
+	l.RemoveCustomData("")
+	if l.Icao != "" || l.Custom || !reflect.DeepEqual(l.Reasons, []Reason{ReasonMissingIcao}) || l.Status() != StatusIncomplete {
+		t.Errorf("Icao = %q, Custom = %v, Reasons = %v after removing the custom data", l.Icao, l.Custom, l.Reasons)
+	}
+	l = tests[3].livery
+	l.RemoveCustomData("DLH")
+	if l.Icao != "DLH" || l.Custom || !l.Included() {
+		t.Errorf("Icao = %q, Custom = %v, Reasons = %v after removing the custom data", l.Icao, l.Custom, l.Reasons)
+	}
+}
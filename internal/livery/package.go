@@ -0,0 +1,176 @@
+/*
+ * MatchMaker - create model matching files for VATSIM vPilot
+ *
+ *  MIT License
+ *
+ *  Copyright (c) 2021 Frank Kopp
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NON-INFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ *
+ */
+
+package livery
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	ManifestFile = "manifest.json"
+	LayoutFile   = "layout.json"
+)
+
+// Package represents a MSFS package (a folder with a manifest.json) which
+// contains one or more liveries or aircraft. The meta data is read from the
+// manifest.json and the layout.json of the package.
+// Folders without a manifest.json are represented as a package with the name of
+// the folder directly below the livery root and without meta data.
+type Package struct {
+	Name           string // folder name of the package - used by MSFS to identify the package
+	Path           string
+	Title          string
+	Creator        string
+	PackageVersion string
+	ContentType    string
+	Dependencies   []Dependency
+	Files          int   // number of files listed in layout.json
+	Size           int64 // size of all files listed in layout.json
+	HasManifest    bool
+}
+
+// Dependency is a package the package depends on as listed in the manifest.json
+type Dependency struct {
+	Name           string `json:"name"`
+	PackageVersion string `json:"package_version"`
+}
+
+// manifest represents the relevant parts of a package manifest.json
+type manifest struct {
+	Title          string       `json:"title"`
+	Creator        string       `json:"creator"`
+	PackageVersion string       `json:"package_version"`
+	ContentType    string       `json:"content_type"`
+	Dependencies   []Dependency `json:"dependencies"`
+}
+
+// layout represents a package layout.json which lists all files of the package
+type layout struct {
+	Content []struct {
+		Path string `json:"path"`
+		Size int64  `json:"size"`
+	} `json:"content"`
+}
+
+// String returns the title of the package and its version if available or its name otherwise
+func (p *Package) String() string {
+	if p == nil {
+		return ""
+	}
+	if p.Title == "" {
+		return p.Name
+	}
+	if p.PackageVersion == "" {
+		return p.Title
+	}
+	return p.Title + " v" + p.PackageVersion
+}
+
+// loadPackage reads the manifest.json and layout.json of the package folder.
+// Returns an error if the manifest.json can't be read or parsed. A missing or
+// broken layout.json is ignored as it only adds statistics.
+func loadPackage(dir string) (*Package, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		return nil, err
+	}
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	p := &Package{
+		Name:           filepath.Base(dir),
+		Path:           dir,
+		Title:          m.Title,
+		Creator:        m.Creator,
+		PackageVersion: m.PackageVersion,
+		ContentType:    m.ContentType,
+		Dependencies:   m.Dependencies,
+		HasManifest:    true,
+	}
+	if data, err := ioutil.ReadFile(filepath.Join(dir, LayoutFile)); err == nil {
+		var l layout
+		if err := json.Unmarshal(data, &l); err == nil {
+			p.Files = len(l.Content)
+			for _, f := range l.Content {
+				p.Size += f.Size
+			}
+		}
+	}
+	return p, nil
+}
+
+// packageLoader finds and loads the package of liveries. Each package is only
+// loaded once per scan.
+type packageLoader struct {
+	packages map[string]*Package // package folder -> package
+}
+
+func newPackageLoader() *packageLoader {
+	return &packageLoader{packages: map[string]*Package{}}
+}
+
+// attachPackages sets the package of each livery found in the given livery root
+func (pl *packageLoader) attachPackages(root string, liveries []*Livery) {
+	for _, l := range liveries {
+		l.Package = pl.find(root, l.AircraftCfgFile)
+	}
+}
+
+// find returns the package an aircraft.cfg file belongs to. This is the nearest
+// folder above the aircraft.cfg with a manifest.json but not above the livery root.
+// If there is none the folder directly below the livery root is used.
+func (pl *packageLoader) find(root string, aircraftCfgFile string) *Package {
+	root = filepath.Clean(root)
+	for dir := filepath.Dir(aircraftCfgFile); strings.HasPrefix(dir, root) && dir != root; dir = filepath.Dir(dir) {
+		if p, found := pl.packages[dir]; found {
+			if p != nil {
+				return p
+			}
+			continue
+		}
+		p, err := loadPackage(dir)
+		if err != nil {
+			pl.packages[dir] = nil // remember that this folder is no package
+			continue
+		}
+		pl.packages[dir] = p
+		return p
+	}
+	name := packageName(root, aircraftCfgFile)
+	dir := filepath.Join(root, name)
+	if p, found := pl.packages[dir]; found && p != nil {
+		return p
+	}
+	p := &Package{Name: name, Path: dir}
+	pl.packages[dir] = p
+	return p
+}
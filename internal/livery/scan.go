@@ -0,0 +1,361 @@
+/*
+ * MatchMaker - create model matching files for VATSIM vPilot
+ *
+ *  MIT License
+ *
+ *  Copyright (c) 2021 Frank Kopp
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NON-INFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ *
+ */
+
+package livery
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/frankkopp/MatchMaker/internal/config"
+	"github.com/karrick/godirwalk"
+)
+
+// ScanLiveryRoots scans all enabled livery roots in the given order and returns
+// the liveries of all roots. The roots are scanned one after the other while the
+// aircraft.cfg files of each root are parsed in parallel.
+// When the same livery title or package is found in more than one root the
+// configured precedence decides which copy is used. The other copies are kept
+// in the result but are marked as duplicates and will not be processed.
+// Files which can't be used and liveries which are incomplete are reported as
+// issues in the result.
+// Liveries in zip archives of the configured archive folders are returned
+// separately as available but not installed.
+func ScanLiveryRoots(roots []config.LiveryRoot) (*ScanResult, error) {
+	s := newScanner()
+	packages := newPackageLoader()
+	var results []rootResult
+	for _, root := range roots {
+		if !root.Enabled {
+			continue
+		}
+		liveries, aircraft, err := s.scanRoot(root.Path)
+		if err != nil {
+			return nil, err
+		}
+		for _, l := range liveries {
+			l.Root = root.Label
+		}
+		for _, a := range aircraft {
+			a.Root = root.Label
+			a.Package = packages.find(root.Path, a.AircraftCfgFile)
+		}
+		packages.attachPackages(root.Path, liveries)
+		markInactivePackages(root.Path, liveries, aircraft)
+		results = append(results, rootResult{root, liveries, aircraft})
+	}
+	s.saveCache()
+
+	precedence := config.Configuration.RootPrecedence()
+	resolveDuplicates(results, precedence)
+	aircraft := newAircraftIndex(byPrecedence(results, precedence))
+	s.result.Issues = append(s.result.Issues, resolveBaseAircraft(results, aircraft)...)
+
+	for _, r := range results {
+		s.result.Liveries = append(s.result.Liveries, r.liveries...)
+	}
+
+	// liveries in zip archives which are not installed yet
+	if dirs := config.Configuration.ArchiveDirs(); len(dirs) > 0 {
+		s.result.Archived = scanArchives(dirs, aircraft, s.result)
+	}
+	return s.result, nil
+}
+
+// ScanLiveryFolder find all aircraft.cfg files as paths with in the start directory
+// and returns the liveries found. See ScanLiveryRoots.
+func ScanLiveryFolder(filePath string) (*ScanResult, error) {
+	return ScanLiveryRoots([]config.LiveryRoot{{Label: filepath.Base(filePath), Path: filePath, Enabled: true}})
+}
+
+// rootResult holds the liveries and base aircraft found in one livery root
+type rootResult struct {
+	root     config.LiveryRoot
+	liveries []*Livery
+	aircraft []*Aircraft
+}
+
+// scanner holds the state of one scan over one or more livery roots
+type scanner struct {
+	cacheFile string
+	cache     *scanCache // cache of the previous scan
+	newCache  *scanCache // cache build during this scan
+	workers   int
+	result    *ScanResult
+}
+
+func newScanner() *scanner {
+	s := &scanner{
+		cacheFile: config.Configuration.ScanCacheFile(),
+		cache:     newScanCache(),
+		newCache:  newScanCache(),
+		workers:   config.Configuration.ScanWorkers(),
+		result:    &ScanResult{Pruned: map[string]int{}, Categories: map[string]int{}},
+	}
+	if s.cacheFile != "" && !*config.Configuration.Rescan {
+		s.cache = loadScanCache(s.cacheFile)
+	}
+	return s
+}
+
+// scanRoot find all aircraft.cfg files as paths with in the start directory
+// and parses them with a pool of workers. The number of workers is taken from
+// the configuration. The result is sorted by aircraft.cfg path and FLTSIM index
+// so it does not depend on the order in which the workers finish.
+// Files which have not changed since the last scan are taken from the scan cache
+// unless a full rescan is requested. Custom data is always applied after the
+// cache lookup so changes to the custom data take effect immediately.
+// The planes found are returned as base aircraft for the liveries.
+func (s *scanner) scanRoot(filePath string) ([]*Livery, []*Aircraft, error) {
+	paths := make(chan string, s.workers*4)
+	results := make(chan fileResult, s.workers*4)
+
+	// workers parsing the aircraft.cfg files fed by the directory walk
+	var wg sync.WaitGroup
+	for i := 0; i < s.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range paths {
+				results <- scanFile(path, s.cache)
+			}
+		}()
+	}
+
+	// collect the results of all workers
+	var files []fileResult
+	done := make(chan struct{})
+	go func() {
+		for r := range results {
+			if r.entry != nil {
+				s.newCache.Files[r.path] = r.entry
+			}
+			if r.cached {
+				s.result.FilesCached++
+			} else {
+				s.result.FilesParsed++
+			}
+			if len(r.liveries) > 0 || len(r.issues) > 0 || r.aircraft != nil {
+				files = append(files, r)
+			}
+		}
+		close(done)
+	}()
+
+	// scan liveries - folders and files excluded by the configuration or ignore files are pruned
+	filter := newPathFilter(filePath, config.Configuration.ScanIncludes(), config.Configuration.ScanExcludes())
+	err := godirwalk.Walk(filePath, &godirwalk.Options{
+		Callback: func(osPathname string, de *godirwalk.Dirent) error {
+			if isDir, _ := de.IsDirOrSymlinkToDir(); isDir {
+				if filter.skipDir(osPathname) {
+					return godirwalk.SkipThis
+				}
+				return nil
+			}
+			if de.IsRegular() {
+				if de.Name() != config.FileName {
+					return godirwalk.SkipThis
+				}
+				if filter.skipFile(osPathname) {
+					return nil
+				}
+				paths <- osPathname
+			}
+			return nil
+		},
+		Unsorted:            true,
+		FollowSymbolicLinks: true,
+	})
+	close(paths)
+	wg.Wait()
+	close(results)
+	<-done
+	for rule, n := range filter.pruned {
+		s.result.Pruned[rule] += n
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// merge in a deterministic order - the liveries of one file are already in FLTSIM order
+	sort.Slice(files, func(i, j int) bool { return files[i].path < files[j].path })
+	// SimObjects of categories which are not configured are dropped including their issues
+	var liveries []*Livery
+	var aircraft []*Aircraft
+	for _, f := range files {
+		for _, l := range f.liveries {
+			s.result.Categories[categoryName(l.Category)]++
+		}
+		used := usedCategory(f.liveries)
+		baseUsed := f.aircraft != nil && config.Configuration.IsCategory(f.aircraft.Category)
+		if baseUsed {
+			aircraft = append(aircraft, f.aircraft)
+		}
+		if len(used) == 0 && !baseUsed && (len(f.liveries) > 0 || f.aircraft != nil) {
+			continue
+		}
+		s.result.Issues = append(s.result.Issues, f.issues...)
+		for _, l := range used {
+			applyCustomData(l, config.Configuration.Custom)
+			applyVariationFilter(l)
+			s.result.Issues = append(s.result.Issues, checkLivery(l)...)
+		}
+		liveries = append(liveries, used...)
+	}
+	return liveries, aircraft, nil
+}
+
+// usedCategory returns the liveries of a configured category or of an unknown category
+func usedCategory(liveries []*Livery) []*Livery {
+	var used []*Livery
+	for _, l := range liveries {
+		if l.Category == "" || config.Configuration.IsCategory(l.Category) {
+			used = append(used, l)
+		}
+	}
+	return used
+}
+
+// categoryName returns the category as shown in the scan report
+func categoryName(category string) string {
+	if category == "" {
+		return "unknown"
+	}
+	return category
+}
+
+// saveCache stores the cache build during this scan. Files not seen in this scan
+// are dropped from the cache.
+func (s *scanner) saveCache() {
+	if s.cacheFile == "" {
+		return
+	}
+	if err := s.newCache.save(s.cacheFile); err != nil {
+		log.Printf("Could not save scan cache %s: %v", s.cacheFile, err)
+	}
+}
+
+// fileResult holds the liveries and issues of one aircraft.cfg file and the
+// cache entry to store for the file
+type fileResult struct {
+	path     string
+	liveries []*Livery
+	aircraft *Aircraft
+	issues   []*Issue
+	entry    *cacheEntry
+	cached   bool
+}
+
+// scanFile returns the liveries of one aircraft.cfg file either from the cache if
+// the file is unchanged or by parsing the file
+func scanFile(path string, cache *scanCache) fileResult {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileResult{path: path, issues: []*Issue{newIssue(path, SeverityError, CategoryUnparseable, "%v", err)}}
+	}
+	if entry := cache.lookup(path, info); entry != nil {
+		return fileResult{path, entry.copyLiveries(), entry.copyAircraft(), entry.Issues, entry, true}
+	}
+	liveries, aircraft, issues := processAircraftCfg(path)
+	entry := newCacheEntry(info, liveries, aircraft, issues)
+	return fileResult{path, entry.copyLiveries(), entry.copyAircraft(), issues, entry, false}
+}
+
+// resolveDuplicates marks liveries as duplicates if the same title or the same
+// package has already been found in a root with higher precedence.
+// precedence "first" gives the first root the highest precedence, "last" the last
+// root and "none" keeps all copies.
+func resolveDuplicates(results []rootResult, precedence string) {
+	if precedence == "none" {
+		return
+	}
+	ordered := byPrecedence(results, precedence)
+
+	type origin struct {
+		rootIndex int
+		livery    *Livery
+	}
+	titles := map[string]origin{}
+	packages := map[string]origin{}
+	for i, r := range ordered {
+		for _, l := range r.liveries {
+			// liveries of disabled packages are not loaded by MSFS and can't take precedence
+			if l.PackageInactive {
+				continue
+			}
+			pkg := l.Package.Name
+			if winner, found := packages[pkg]; found && winner.rootIndex != i {
+				markDuplicate(l, winner.livery.AircraftCfgFile)
+				continue
+			}
+			packages[pkg] = origin{i, l}
+			if winner, found := titles[l.Title]; found && l.Title != "" && winner.rootIndex != i {
+				markDuplicate(l, winner.livery.AircraftCfgFile)
+				continue
+			}
+			titles[l.Title] = origin{i, l}
+		}
+	}
+}
+
+// byPrecedence returns the root results ordered from the highest to the lowest precedence
+func byPrecedence(results []rootResult, precedence string) []rootResult {
+	ordered := make([]rootResult, len(results))
+	copy(ordered, results)
+	if precedence == "last" {
+		for i, j := 0, len(ordered)-1; i < j; i, j = i+1, j-1 {
+			ordered[i], ordered[j] = ordered[j], ordered[i]
+		}
+	}
+	return ordered
+}
+
+func markDuplicate(l *Livery, duplicateOf string) {
+	l.DuplicateOf = duplicateOf
+	l.SetReason(ReasonDuplicate, true)
+	if *config.Configuration.Verbose {
+		log.Printf("Duplicate livery %s (%s) - using %s\n", l.AircraftCfgFile, l.Root, duplicateOf)
+	}
+}
+
+// packageName returns the name of the package folder - the first folder below the
+// livery root - of an aircraft.cfg file
+func packageName(root string, aircraftCfgFile string) string {
+	rel, err := filepath.Rel(root, aircraftCfgFile)
+	if err != nil {
+		return ""
+	}
+	rel = filepath.ToSlash(rel)
+	if idx := strings.Index(rel, "/"); idx >= 0 {
+		return rel[:idx]
+	}
+	return rel
+}
@@ -0,0 +1,186 @@
+/*
+ * MatchMaker - create model matching files for VATSIM vPilot
+ *
+ *  MIT License
+ *
+ *  Copyright (c) 2021 Frank Kopp
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NON-INFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ *
+ */
+
+package livery
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/frankkopp/MatchMaker/internal/config"
+)
+
+func TestScanWorkersOrder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "workers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{}
+	for i := 0; i < 40; i++ {
+		icao := fmt.Sprintf("icao_airline = \"A%02d\"\n", i)
+		if i%5 == 0 {
+			icao = "" // issues are reported in the same order as well
+		}
+		files[fmt.Sprintf("pack-%02d/SimObjects/Airplanes/Asobo_A320_NEO-%02d/aircraft.cfg", i, i)] =
+			"[VARIATION]\nbase_container = \"..\\Asobo_A320_NEO\"\n" +
+				fmt.Sprintf("[FLTSIM.0]\ntitle = \"A320 %02d\"\n%s[FLTSIM.1]\ntitle = \"A320 %02d AI\"\n%s", i, icao, i, icao)
+	}
+	writeFiles(t, dir, files)
+
+	scan := func(workers int) []string {
+		setupConfig(t, fmt.Sprintf("[scan]\ncacheFile =\nignoreContentXml = true\nworkers = %d\n"+
+			"[defaultTypes]\nAsobo_A320_NEO = Airbus A320 Neo Asobo\n", workers))
+		result, err := ScanLiveryFolder(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Liveries) != 80 {
+			t.Fatalf("scan with %d workers found %d liveries, want 80", workers, len(result.Liveries))
+		}
+		var got []string
+		for _, l := range result.Liveries {
+			got = append(got, l.AircraftCfgFile+" "+l.Title)
+		}
+		for _, i := range result.Issues {
+			got = append(got, i.String())
+		}
+		return got
+	}
+	want := scan(1)
+	for _, workers := range []int{2, 8, 32} {
+		if got := scan(workers); !reflect.DeepEqual(got, want) {
+			t.Errorf("scan with %d workers = %v, want %v", workers, got, want)
+		}
+	}
+}
+
+func TestResolveDuplicates(t *testing.T) {
+	setupConfig(t, "[scan]\ncacheFile =\n")
+	// a1 and b1 have the same title, a2 and b2 are in the same package, b3 and b4
+	// have the same title but are in the same root
+	newResults := func() []rootResult {
+		livery := func(file, title, pkg string) *Livery {
+			return &Livery{AircraftCfgFile: file, Title: title, Package: &Package{Name: pkg}}
+		}
+		return []rootResult{
+			{root: config.LiveryRoot{Label: "A"}, liveries: []*Livery{
+				livery("a1", "T1", "pack-a"),
+				livery("a2", "T2", "pack-shared"),
+			}},
+			{root: config.LiveryRoot{Label: "B"}, liveries: []*Livery{
+				livery("b1", "T1", "pack-b"),
+				livery("b2", "T3", "pack-shared"),
+				livery("b3", "T4", "pack-b3"),
+				livery("b4", "T4", "pack-b4"),
+			}},
+		}
+	}
+	tests := []struct {
+		precedence string
+		roots      []string
+		want       map[string]string // duplicate -> livery used instead
+	}{
+		{"first", []string{"A", "B"}, map[string]string{"b1": "a1", "b2": "a2"}},
+		{"last", []string{"B", "A"}, map[string]string{"a1": "b1", "a2": "b2"}},
+		{"none", []string{"A", "B"}, map[string]string{}},
+	}
+	for _, tt := range tests {
+		results := newResults()
+		var roots []string
+		for _, r := range byPrecedence(results, tt.precedence) {
+			roots = append(roots, r.root.Label)
+		}
+		if !reflect.DeepEqual(roots, tt.roots) {
+			t.Errorf("%s: byPrecedence() = %v, want %v", tt.precedence, roots, tt.roots)
+		}
+		resolveDuplicates(results, tt.precedence)
+		got := map[string]string{}
+		for _, r := range results {
+			for _, l := range r.liveries {
+				if l.HasReason(ReasonDuplicate) {
+					got[l.AircraftCfgFile] = l.DuplicateOf
+				} else if l.DuplicateOf != "" {
+					t.Errorf("%s: %s has DuplicateOf %s but no duplicate reason", tt.precedence, l.AircraftCfgFile, l.DuplicateOf)
+				}
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: duplicates = %v, want %v", tt.precedence, got, tt.want)
+		}
+	}
+}
+
+func TestScanRootPrecedence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "roots")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cfg := "[VARIATION]\nbase_container = \"..\\Asobo_A320_NEO\"\n[FLTSIM.0]\ntitle = \"A320 Lufthansa\"\nicao_airline = \"DLH\"\n"
+	writeFiles(t, dir, map[string]string{
+		"Community/dlh-pack/SimObjects/Airplanes/Asobo_A320_NEO-DLH/aircraft.cfg": cfg,
+		"OneStore/dlh-pack/SimObjects/Airplanes/Asobo_A320_NEO-DLH/aircraft.cfg":  cfg,
+	})
+	roots := []config.LiveryRoot{
+		{Label: "Community", Path: filepath.Join(dir, "Community"), Enabled: true},
+		{Label: "Official", Path: filepath.Join(dir, "OneStore"), Enabled: true},
+	}
+	tests := []struct {
+		precedence string
+		used       []string // roots of the liveries used
+	}{
+		{"", []string{"Community"}},
+		{"first", []string{"Community"}},
+		{"last", []string{"Official"}},
+		{"none", []string{"Community", "Official"}},
+	}
+	for _, tt := range tests {
+		setupConfig(t, "[scan]\ncacheFile =\nignoreContentXml = true\nexcludeMissingBase = false\nprecedence = "+tt.precedence+"\n"+
+			"[defaultTypes]\nAsobo_A320_NEO = Airbus A320 Neo Asobo\n")
+		result, err := ScanLiveryRoots(roots)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Liveries) != 2 {
+			t.Fatalf("%s: found %d liveries, want 2", tt.precedence, len(result.Liveries))
+		}
+		var used []string
+		for _, l := range result.Liveries {
+			if l.Included() {
+				used = append(used, l.Root)
+			}
+		}
+		if !reflect.DeepEqual(used, tt.used) {
+			t.Errorf("precedence %q: used liveries of %v, want %v", tt.precedence, used, tt.used)
+		}
+	}
+}
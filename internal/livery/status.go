@@ -0,0 +1,264 @@
+/*
+ * MatchMaker - create model matching files for VATSIM vPilot
+ *
+ *  MIT License
+ *
+ *  Copyright (c) 2021 Frank Kopp
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NON-INFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ *
+ */
+
+package livery
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Status tells if a livery is used for rules and if not how it could be used
+type Status int
+
+// the status of a livery from used to not usable at all - the status of a
+// livery is the highest status of its reasons
+const (
+	StatusIncluded   Status = iota // rules are created for the livery
+	StatusDisabled                 // disabled by custom data - can be activated
+	StatusIncomplete               // data or configuration is missing
+	StatusBlocked                  // can't be used independent of its data or custom data
+)
+
+var statusNames = [...]string{"included", "disabled", "incomplete", "blocked"}
+
+func (s Status) String() string {
+	return statusNames[s]
+}
+
+// Reason is a reason why a livery is not used for rules
+type Reason int
+
+// reasons in the order of their importance - the first reason of a livery is
+// its primary reason
+const (
+	ReasonArchived          Reason = iota // only available in an archive - not installed
+	ReasonDuplicate                       // a livery in a root with higher precedence is used
+	ReasonPackageInactive                 // package is disabled in the content.xml
+	ReasonBaseMissing                     // base aircraft is not installed
+	ReasonUserOnly                        // user only variation excluded by configuration
+	ReasonAiOnly                          // AI only variation excluded by configuration
+	ReasonMissingTitle                    // FLTSIM section has no title
+	ReasonMissingIcao                     // no icao_airline and no custom ICAO
+	ReasonBaseNotConfigured               // base container is not in [defaultTypes]
+	ReasonDisabledByCustom                // process flag of the custom data is false
+	numberOfReasons
+)
+
+var reasonNames = [...]string{
+	"not installed",
+	"duplicate",
+	"package inactive",
+	"base aircraft missing",
+	"user only variation",
+	"AI only variation",
+	"missing title",
+	"missing ICAO",
+	"base container not configured",
+	"disabled by custom data",
+}
+
+func (r Reason) String() string {
+	return reasonNames[r]
+}
+
+// Status returns the status derived from the reasons of the livery
+func (r Reason) Status() Status {
+	switch r {
+	case ReasonDisabledByCustom:
+		return StatusDisabled
+	case ReasonMissingTitle, ReasonMissingIcao, ReasonBaseNotConfigured:
+		return StatusIncomplete
+	default:
+		return StatusBlocked
+	}
+}
+
+// Reasons returns all reasons in the order of their importance
+func Reasons() []Reason {
+	reasons := make([]Reason, numberOfReasons)
+	for i := range reasons {
+		reasons[i] = Reason(i)
+	}
+	return reasons
+}
+
+// Status returns the status of the livery
+func (l *Livery) Status() Status {
+	status := StatusIncluded
+	for _, r := range l.Reasons {
+		if r.Status() > status {
+			status = r.Status()
+		}
+	}
+	return status
+}
+
+// Included returns true if rules are created for the livery
+func (l *Livery) Included() bool {
+	return len(l.Reasons) == 0
+}
+
+// Toggleable returns true if only the process flag of the custom data decides
+// if rules are created for the livery - it is either included or only disabled
+// by its custom data
+func (l *Livery) Toggleable() bool {
+	for _, r := range l.Reasons {
+		if r != ReasonDisabledByCustom {
+			return false
+		}
+	}
+	return true
+}
+
+// Installable returns true if rules would be created for a livery of an archive
+// once it is installed
+func (l *Livery) Installable() bool {
+	for _, r := range l.Reasons {
+		if r != ReasonArchived {
+			return false
+		}
+	}
+	return true
+}
+
+// Complete returns true if the livery has a title and an ICAO - its base
+// container might still not be configured or it might be disabled or blocked.
+func (l *Livery) Complete() bool {
+	return !l.HasReason(ReasonMissingTitle) && !l.HasReason(ReasonMissingIcao)
+}
+
+// Blocked returns true if the livery can't be processed independent of its data
+// or custom data. E.g. a duplicate, disabled package, excluded variation or a
+// livery which is only available in an archive.
+func (l *Livery) Blocked() bool {
+	return l.Status() == StatusBlocked
+}
+
+// HasReason returns true if the reason applies to the livery
+func (l *Livery) HasReason(reason Reason) bool {
+	for _, r := range l.Reasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// Reason returns the most important reason why the livery is not used for rules
+// and false if the livery is included.
+func (l *Livery) Reason() (Reason, bool) {
+	if len(l.Reasons) == 0 {
+		return 0, false
+	}
+	return l.Reasons[0], true
+}
+
+// SetReason adds or removes the reason and keeps the reasons ordered by importance
+func (l *Livery) SetReason(reason Reason, set bool) {
+	for i, r := range l.Reasons {
+		if r == reason {
+			if set {
+				return
+			}
+			l.Reasons = append(l.Reasons[:i:i], l.Reasons[i+1:]...)
+			if len(l.Reasons) == 0 {
+				l.Reasons = nil
+			}
+			return
+		}
+	}
+	if set {
+		l.Reasons = append(l.Reasons, reason)
+		sort.Slice(l.Reasons, func(i, j int) bool { return l.Reasons[i] < l.Reasons[j] })
+	}
+}
+
+// SetIcao sets a custom ICAO for the livery - an empty ICAO makes the livery incomplete
+func (l *Livery) SetIcao(icao string) {
+	l.Icao = icao
+	l.Custom = true
+	l.SetReason(ReasonMissingIcao, icao == "")
+}
+
+// Remark describes all reasons why the livery is not used for rules
+func (l *Livery) Remark() string {
+	remarks := make([]string, len(l.Reasons))
+	for i, r := range l.Reasons {
+		remarks[i] = l.reasonText(r)
+	}
+	return strings.Join(remarks, "; ")
+}
+
+// reasonText describes the reason with the details of the livery
+func (l *Livery) reasonText(r Reason) string {
+	switch r {
+	case ReasonArchived:
+		return "available in " + filepath.Base(l.Archive) + " - not installed"
+	case ReasonDuplicate:
+		return "duplicate of " + l.DuplicateOf
+	case ReasonPackageInactive:
+		return "package " + l.Package.Name + " is disabled in " + ContentXmlFile
+	case ReasonBaseMissing:
+		return "base aircraft " + l.BaseContainer + " is not installed"
+	case ReasonUserOnly:
+		return "user only variation (isAirTraffic=0)"
+	case ReasonAiOnly:
+		return "AI only variation (isUserSelectable=0)"
+	case ReasonBaseNotConfigured:
+		return fmt.Sprintf("base container %q is not configured in [defaultTypes]", l.BaseContainer)
+	default:
+		return r.String()
+	}
+}
+
+// ReasonCount is the number of liveries not used for rules for one reason
+type ReasonCount struct {
+	Reason Reason
+	Count  int
+}
+
+// CountReasons returns the number of liveries per reason in the order of the
+// reasons - reasons no livery has are left out. A livery is counted for each
+// of its reasons.
+func CountReasons(liveries []*Livery) []ReasonCount {
+	counts := make([]int, numberOfReasons)
+	for _, l := range liveries {
+		for _, r := range l.Reasons {
+			counts[r]++
+		}
+	}
+	var result []ReasonCount
+	for r, c := range counts {
+		if c > 0 {
+			result = append(result, ReasonCount{Reason(r), c})
+		}
+	}
+	return result
+}
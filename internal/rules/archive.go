@@ -0,0 +1,100 @@
+/*
+ * MatchMaker - create model matching files for VATSIM vPilot
+ *
+ *  MIT License
+ *
+ *  Copyright (c) 2021 Frank Kopp
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NON-INFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ *
+ */
+
+package rules
+
+import (
+	"sort"
+
+	"github.com/frankkopp/MatchMaker/internal/livery"
+)
+
+// ArchiveGaps returns the archives which have liveries for ICAO and type code
+// combinations no installed livery is used for. The ICAOs and type codes are
+// mapped like for the rules - with ICAO variations, subtype patterns and the
+// type codes of the base aircraft. Only archived liveries which would be used
+// for rules once installed are considered. The archives with the most gaps
+// filled come first.
+func (e *Engine) ArchiveGaps(installed, archived []*livery.Livery) []*livery.ArchiveGap {
+	// the type codes of base aircraft in archives are used once they are installed
+	typeVariations, _ := e.prepare(append(append([]*livery.Livery{}, installed...), archived...))
+
+	covered := map[string]bool{}
+	for _, l := range installed {
+		if l.Included() && l.Icao != "" {
+			for _, key := range e.ruleKeys(l, typeVariations) {
+				covered[key] = true
+			}
+		}
+	}
+	gaps := map[string]*livery.ArchiveGap{}
+	fills := map[string]map[string]bool{}
+	for _, l := range archived {
+		if !l.Installable() || l.Icao == "" {
+			continue
+		}
+		gap, found := gaps[l.Archive]
+		if !found {
+			gap = &livery.ArchiveGap{Archive: l.Archive}
+			gaps[l.Archive] = gap
+			fills[l.Archive] = map[string]bool{}
+		}
+		gap.Liveries++
+		for _, key := range e.ruleKeys(l, typeVariations) {
+			if !covered[key] && !fills[l.Archive][key] {
+				fills[l.Archive][key] = true
+				gap.Fills = append(gap.Fills, key)
+			}
+		}
+	}
+	var result []*livery.ArchiveGap
+	for _, gap := range gaps {
+		if len(gap.Fills) > 0 {
+			sort.Strings(gap.Fills)
+			result = append(result, gap)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if len(result[i].Fills) != len(result[j].Fills) {
+			return len(result[i].Fills) > len(result[j].Fills)
+		}
+		return result[i].Archive < result[j].Archive
+	})
+	return result
+}
+
+// ruleKeys returns the ICAO/type code combinations (e.g. "DLH/A20N") rules are
+// created for with the livery - see collect
+func (e *Engine) ruleKeys(l *livery.Livery, typeVariations map[string][]string) []string {
+	var keys []string
+	for _, icao := range findIcaoVariations(l, e.config.IcaoVariations) {
+		for _, typeCode := range e.liveryTypeCodes(l, typeVariations[l.BaseContainer]) {
+			keys = append(keys, icao+"/"+typeCode)
+		}
+	}
+	return keys
+}
@@ -0,0 +1,89 @@
+/*
+ * MatchMaker - create model matching files for VATSIM vPilot
+ *
+ *  MIT License
+ *
+ *  Copyright (c) 2021 Frank Kopp
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NON-INFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ *
+ */
+
+package rules
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/frankkopp/MatchMaker/internal/config"
+	"github.com/frankkopp/MatchMaker/internal/livery"
+)
+
+func TestEngineArchiveGaps(t *testing.T) {
+	const ini = `
+[defaultTypes]
+Asobo_A320_NEO = Airbus A320 Neo Asobo
+Asobo_CJ4      = Cessna CJ4 Citation Asobo
+[typeVariations]
+Asobo_A320_NEO = A320,A321
+[icaoVariations]
+Lufthansa = DLH,CLH
+[subtypePatterns]
+A321 = A321
+`
+	if err := config.Configuration.LoadFromString(ini); err != nil {
+		t.Fatal(err)
+	}
+	installed := []*livery.Livery{
+		{Title: "A320 Lufthansa", Icao: "DLH", BaseContainer: "Asobo_A320_NEO"},
+	}
+	cj4 := &livery.Aircraft{IcaoTypeDesignator: "C25C"}
+	archive := func(file string, l *livery.Livery, reasons ...livery.Reason) *livery.Livery {
+		l.Archive = file
+		for _, r := range append(reasons, livery.ReasonArchived) {
+			l.SetReason(r, true)
+		}
+		return l
+	}
+	archived := []*livery.Livery{
+		// CLH is covered by the DLH livery of its ICAO variation
+		archive("a.zip", &livery.Livery{Title: "A320 CityLine", Icao: "CLH", BaseContainer: "Asobo_A320_NEO"}),
+		// the subtype pattern limits the livery to A321
+		archive("a.zip", &livery.Livery{Title: "A321 Iberia", Icao: "IBE", BaseContainer: "Asobo_A320_NEO"}),
+		// the type code of the base aircraft is used without type variations
+		archive("b.zip", &livery.Livery{Title: "CJ4 NetJets", Icao: "EJA", BaseContainer: "Asobo_CJ4", BaseAircraft: cj4}),
+		archive("b.zip", &livery.Livery{Title: "CJ4 NetJets Europe", Icao: "NJE", BaseContainer: "Asobo_CJ4", BaseAircraft: cj4}),
+		// liveries which would not be used once installed fill no gaps
+		archive("b.zip", &livery.Livery{Title: "A320 easyJet AI", Icao: "EZY", BaseContainer: "Asobo_A320_NEO"}, livery.ReasonAiOnly),
+		archive("c.zip", &livery.Livery{Title: "A320 Lufthansa Retro", Icao: "DLH", BaseContainer: "Asobo_A320_NEO"}),
+	}
+	gaps := NewEngine(NewConfig(&config.Configuration)).ArchiveGaps(installed, archived)
+	want := []livery.ArchiveGap{
+		{Archive: "b.zip", Liveries: 2, Fills: []string{"EJA/C25C", "NJE/C25C"}},
+		{Archive: "a.zip", Liveries: 2, Fills: []string{"IBE/A321"}},
+	}
+	if len(gaps) != len(want) {
+		t.Fatalf("ArchiveGaps() = %d archives, want %d", len(gaps), len(want))
+	}
+	for i, gap := range gaps {
+		if !reflect.DeepEqual(*gap, want[i]) {
+			t.Errorf("ArchiveGaps()[%d] = %+v, want %+v", i, *gap, want[i])
+		}
+	}
+}
@@ -0,0 +1,143 @@
+/*
+ * MatchMaker - create model matching files for VATSIM vPilot
+ *
+ *  MIT License
+ *
+ *  Copyright (c) 2021 Frank Kopp
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NON-INFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ *
+ */
+
+package rules
+
+import "sort"
+
+// crossBaseEnabled returns true if the ICAO uses its liveries of other base
+// containers for the type codes it has no livery for
+func (e *Engine) crossBaseEnabled(icao string) bool {
+	if enabled, ok := e.config.CrossBaseIcaos[icao]; ok {
+		return enabled
+	}
+	return e.config.CrossBaseFallback
+}
+
+// addCrossBase adds the rules for the type codes an ICAO with cross base
+// fallback has no livery for. They use the liveries of the ICAO on the base
+// container closest to a base container listing the type code. Returns the
+// number of type codes per ICAO which use a cross base fallback.
+func (e *Engine) addCrossBase(candidates map[string]map[string][]candidate, models map[string]map[string][]string, bases []string, typeVariations map[string][]string) map[string]int {
+	// base containers in the order of the type variations - base containers
+	// with the type codes of the base aircraft at the end
+	order := append([]string{}, e.config.BaseOrder...)
+	var others []string
+	for baseContainer := range typeVariations {
+		if indexOf(order, baseContainer) < 0 {
+			others = append(others, baseContainer)
+		}
+	}
+	sort.Strings(others)
+	order = append(order, others...)
+
+	added := map[string]int{}
+	for icao, types := range candidates {
+		if icao == "" || !e.crossBaseEnabled(icao) {
+			continue
+		}
+		// the own liveries of the ICAO per base container
+		onBase := map[string][]candidate{}
+		for _, baseContainer := range bases {
+			seen := map[string]bool{}
+			for _, typeCode := range typeVariations[baseContainer] {
+				for _, c := range types[typeCode] {
+					if c.baseContainer == baseContainer && !seen[c.title] {
+						seen[c.title] = true
+						onBase[baseContainer] = append(onBase[baseContainer], c)
+					}
+				}
+			}
+		}
+		if len(onBase) == 0 {
+			continue
+		}
+		for _, target := range bases {
+			for _, typeCode := range typeVariations[target] {
+				if len(models[icao][typeCode]) > 0 {
+					continue
+				}
+				closest := e.closestBase(target, onBase, order)
+				if closest == "" {
+					continue
+				}
+				if titles := e.selectTitles(onBase[closest]); len(titles) > 0 {
+					if models[icao] == nil {
+						models[icao] = map[string][]string{}
+					}
+					models[icao][typeCode] = titles
+					added[icao]++
+				}
+			}
+		}
+	}
+	return added
+}
+
+// closestBase returns the other base container with liveries which is closest
+// to the target base container or "" if there is none. If the target is part of a
+// family of [typeFamilies] only the base containers of its first family are
+// used and ranked by their distance in the family. Otherwise all base
+// containers are ranked by their distance in the order of the type variations.
+// The earlier base container wins a tie.
+func (e *Engine) closestBase(target string, onBase map[string][]candidate, order []string) string {
+	for _, family := range e.config.TypeFamilies {
+		if indexOf(family.BaseContainers, target) >= 0 {
+			order = family.BaseContainers
+			break
+		}
+	}
+	t := indexOf(order, target)
+	if t < 0 {
+		return ""
+	}
+	closest, distance := "", -1
+	for i, baseContainer := range order {
+		// liveries of the target itself are not used for type codes [subtypePatterns] excluded them from
+		if baseContainer == target || len(onBase[baseContainer]) == 0 {
+			continue
+		}
+		d := i - t
+		if d < 0 {
+			d = -d
+		}
+		if distance < 0 || d < distance {
+			closest, distance = baseContainer, d
+		}
+	}
+	return closest
+}
+
+// indexOf returns the position of the value in the list or -1
+func indexOf(list []string, value string) int {
+	for i, v := range list {
+		if v == value {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,156 @@
+/*
+ * MatchMaker - create model matching files for VATSIM vPilot
+ *
+ *  MIT License
+ *
+ *  Copyright (c) 2021 Frank Kopp
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NON-INFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ *
+ */
+
+package rules
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/frankkopp/MatchMaker/internal/config"
+	"github.com/frankkopp/MatchMaker/internal/livery"
+)
+
+func TestEngineCrossBase(t *testing.T) {
+	const ini = `
+[defaultTypes]
+Asobo_A320_NEO = Airbus A320 Neo Asobo
+Asobo_A310     = Airbus A310 Default
+Asobo_B747_8i  = Boeing 747-8i Asobo
+Asobo_B787_10  = Boeing 787-10 Asobo
+Asobo_CJ4      = Cessna CJ4 Citation Asobo
+[typeVariations]
+Asobo_A320_NEO = A320
+Asobo_A310     = A310
+Asobo_B747_8i  = B748,B744
+Asobo_B787_10  = B789
+Asobo_CJ4      = C25C
+`
+	liveries := []*livery.Livery{
+		{Title: "A320 Lufthansa", Icao: "DLH", BaseContainer: "Asobo_A320_NEO"},
+		{Title: "B787 Lufthansa", Icao: "DLH", BaseContainer: "Asobo_B787_10"},
+		{Title: "A320 Condor", Icao: "CFG", BaseContainer: "Asobo_A320_NEO"},
+	}
+	tests := []struct {
+		name   string
+		config string
+		want   map[string][]string // "ICAO TypeCode" -> models
+	}{
+		{"disabled", "", map[string][]string{
+			"DLH B744": nil,
+			"CFG B744": nil,
+		}},
+		{"type variations order", "[rules]\ncrossBaseFallback = true\n", map[string][]string{
+			"DLH A310": {"A320 Lufthansa"},
+			"DLH B744": {"B787 Lufthansa"},
+			"DLH B748": {"B787 Lufthansa"},
+			"DLH C25C": {"B787 Lufthansa"},
+			"DLH B789": {"B787 Lufthansa"},
+			"CFG B744": {"A320 Condor"},
+		}},
+		{"per ICAO", "[crossBaseIcaos]\nCFG = true\n", map[string][]string{
+			"DLH B744": nil,
+			"CFG B744": {"A320 Condor"},
+		}},
+		{"per ICAO disabled", "[rules]\ncrossBaseFallback = true\n[crossBaseIcaos]\nCFG = false\n", map[string][]string{
+			"DLH B744": {"B787 Lufthansa"},
+			"CFG B744": nil,
+		}},
+		{"type families", "[rules]\ncrossBaseFallback = true\n[typeFamilies]\nWidebody = Asobo_A310,Asobo_B747_8i,Asobo_A320_NEO,Asobo_B787_10\n", map[string][]string{
+			"DLH A310": {"A320 Lufthansa"},
+			"DLH B744": {"A320 Lufthansa"}, // closer in the family than the B787
+			"DLH C25C": {"B787 Lufthansa"},
+			"CFG A310": {"A320 Condor"},
+		}},
+		{"no base of the family", "[rules]\ncrossBaseFallback = true\n[typeFamilies]\nBusiness = Asobo_CJ4\n", map[string][]string{
+			"DLH C25C": nil,
+			"DLH A310": {"A320 Lufthansa"},
+		}},
+	}
+	for _, tt := range tests {
+		if err := config.Configuration.LoadFromString(ini + tt.config); err != nil {
+			t.Fatal(err)
+		}
+		rs := NewEngine(NewConfig(&config.Configuration)).Calculate(liveries)
+		for key, want := range tt.want {
+			fields := strings.Fields(key)
+			icao, typeCode := fields[0], fields[1]
+			if got := rs.Models(icao, typeCode); len(got) != len(want) || len(want) > 0 && !reflect.DeepEqual(got, want) {
+				t.Errorf("%s: Models(%s, %s) = %v, want %v", tt.name, icao, typeCode, got, want)
+			}
+		}
+		// the defaults are not changed
+		if got := rs.Models("", "B744"); !reflect.DeepEqual(got, []string{"Boeing 747-8i Asobo"}) {
+			t.Errorf("%s: Models(\"\", B744) = %v", tt.name, got)
+		}
+	}
+}
+
+func TestEngineCrossBaseSubtypes(t *testing.T) {
+	const ini = `
+[defaultTypes]
+Asobo_A320_NEO = Airbus A320 Neo Asobo
+Asobo_A310     = Airbus A310 Default
+[typeVariations]
+Asobo_A320_NEO = A320,A321,A319
+Asobo_A310     = A310
+[subtypePatterns]
+A321 = A321
+[rules]
+crossBaseFallback = true
+`
+	if err := config.Configuration.LoadFromString(ini); err != nil {
+		t.Fatal(err)
+	}
+	liveries := []*livery.Livery{
+		{Title: "A321 Lufthansa", Icao: "DLH", BaseContainer: "Asobo_A320_NEO"},
+		{Title: "A321 Condor", Icao: "CFG", BaseContainer: "Asobo_A320_NEO"},
+		{Title: "A310 Condor", Icao: "CFG", BaseContainer: "Asobo_A310"},
+	}
+	rs := NewEngine(NewConfig(&config.Configuration)).Calculate(liveries)
+	// the subtype limited liveries are not used for the other type codes of their own base container
+	want := map[string][]string{
+		"DLH A321": {"A321 Lufthansa"},
+		"DLH A320": nil,
+		"DLH A319": nil,
+		"DLH A310": {"A321 Lufthansa"},
+		"CFG A321": {"A321 Condor"},
+		"CFG A320": {"A310 Condor"},
+		"CFG A319": {"A310 Condor"},
+		"CFG A310": {"A310 Condor"},
+	}
+	for key, models := range want {
+		fields := strings.Fields(key)
+		if got := rs.Models(fields[0], fields[1]); len(got) != len(models) || len(models) > 0 && !reflect.DeepEqual(got, models) {
+			t.Errorf("Models(%s, %s) = %v, want %v", fields[0], fields[1], got, models)
+		}
+	}
+	if got := rs.Stats().CrossBase; got != 3 {
+		t.Errorf("Stats().CrossBase = %d, want 3", got)
+	}
+}
@@ -0,0 +1,204 @@
+/*
+ * MatchMaker - create model matching files for VATSIM vPilot
+ *
+ *  MIT License
+ *
+ *  Copyright (c) 2021 Frank Kopp
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NON-INFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ *
+ */
+
+package rules
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// RuleChange is a rule which differs between the rules file and a rule set
+type RuleChange struct {
+	Icao          string // CallsignPrefix - empty for default rules
+	TypeCode      string
+	BaseContainer string // base container of the type code - empty if unknown
+	Old           string // ModelName in the rules file - empty if added
+	New           string // ModelName of the rule set - empty if removed
+}
+
+// Diff are the rules added, removed and changed compared to a rules file
+type Diff struct {
+	File    string
+	Added   []RuleChange
+	Removed []RuleChange
+	Changed []RuleChange
+}
+
+// Empty returns true if the rules are the same
+func (d *Diff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// Summary returns the number of added, removed and changed rules as one line
+func (d *Diff) Summary() string {
+	if d.Empty() {
+		return "no rules changed"
+	}
+	return fmt.Sprintf("%d rules added, %d removed, %d changed", len(d.Added), len(d.Removed), len(d.Changed))
+}
+
+// DiffFile compares the rules with the rules of a VMR file rule by rule. A file
+// which does not exist has no rules. Rules of the file which can't be parsed
+// are returned as issues and are treated as removed.
+func (rs *RuleSet) DiffFile(file string) (*Diff, []VMRIssue, error) {
+	var current []ModelMatchRule
+	var issues []VMRIssue
+	if _, err := os.Stat(file); err == nil {
+		current, issues, err = LoadVMRFiles([]string{file})
+		if err != nil {
+			return nil, issues, err
+		}
+	}
+	d := rs.Diff(current)
+	d.File = file
+	return d, issues, nil
+}
+
+// Diff compares the rules with the rules of a VMR file by CallsignPrefix,
+// TypeCode and ModelName. The changes are ordered by ICAO (defaults first),
+// base container and type code.
+func (rs *RuleSet) Diff(current []ModelMatchRule) *Diff {
+	type key struct{ icao, typeCode string }
+	// rules with the same key have the same models - e.g. a type code of several base containers
+	before := map[key]string{}
+	for _, r := range current {
+		before[key{r.CallsignPrefix, r.TypeCode}] = strings.Join(splitModels(r.ModelName), "//")
+	}
+	bases := map[string]string{}
+	after := map[key]string{}
+	for _, r := range rs.rules {
+		if _, found := bases[r.TypeCode]; !found || bases[r.TypeCode] == "" {
+			bases[r.TypeCode] = r.BaseContainer
+		}
+		after[key{r.Icao, r.TypeCode}] = strings.Join(r.Models, "//")
+	}
+
+	d := &Diff{}
+	for k, models := range after {
+		old, found := before[k]
+		change := RuleChange{Icao: k.icao, TypeCode: k.typeCode, BaseContainer: bases[k.typeCode], Old: old, New: models}
+		switch {
+		case !found:
+			d.Added = append(d.Added, change)
+		case old != models:
+			d.Changed = append(d.Changed, change)
+		}
+	}
+	for k, old := range before {
+		if _, found := after[k]; !found {
+			d.Removed = append(d.Removed, RuleChange{Icao: k.icao, TypeCode: k.typeCode, BaseContainer: bases[k.typeCode], Old: old})
+		}
+	}
+	for _, changes := range [][]RuleChange{d.Added, d.Removed, d.Changed} {
+		sortChanges(changes)
+	}
+	return d
+}
+
+func sortChanges(changes []RuleChange) {
+	sort.Slice(changes, func(i, j int) bool {
+		a, b := changes[i], changes[j]
+		if a.Icao != b.Icao {
+			return a.Icao < b.Icao
+		}
+		if a.BaseContainer != b.BaseContainer {
+			return a.BaseContainer < b.BaseContainer
+		}
+		return a.TypeCode < b.TypeCode
+	})
+}
+
+// WriteReport writes the summary and all changes grouped by ICAO and base
+// container. Added rules are marked with "+", removed with "-" and changed
+// with "~".
+func (d *Diff) WriteReport(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "Rules compared to %s: %s\n", d.File, d.Summary()); err != nil {
+		return err
+	}
+	type marked struct {
+		mark string
+		RuleChange
+	}
+	var all []marked
+	for _, c := range d.Added {
+		all = append(all, marked{"+", c})
+	}
+	for _, c := range d.Removed {
+		all = append(all, marked{"-", c})
+	}
+	for _, c := range d.Changed {
+		all = append(all, marked{"~", c})
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		a, b := all[i], all[j]
+		if a.Icao != b.Icao {
+			return a.Icao < b.Icao
+		}
+		return a.BaseContainer < b.BaseContainer
+	})
+
+	icao, base := "-", "-"
+	for _, c := range all {
+		if c.Icao != icao {
+			icao, base = c.Icao, "-"
+			name := "ICAO " + icao
+			if icao == "" {
+				name = "DEFAULTS"
+			}
+			if _, err := fmt.Fprintf(w, "%s\n", name); err != nil {
+				return err
+			}
+		}
+		if c.BaseContainer != base {
+			base = c.BaseContainer
+			name := base
+			if name == "" {
+				name = "unknown base container"
+			}
+			if _, err := fmt.Fprintf(w, "  BASE %s\n", name); err != nil {
+				return err
+			}
+		}
+		var err error
+		switch c.mark {
+		case "+":
+			_, err = fmt.Fprintf(w, "    + %s: %s\n", c.TypeCode, c.New)
+		case "-":
+			_, err = fmt.Fprintf(w, "    - %s: %s\n", c.TypeCode, c.Old)
+		default:
+			_, err = fmt.Fprintf(w, "    ~ %s: %s => %s\n", c.TypeCode, c.Old, c.New)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
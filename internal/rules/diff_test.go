@@ -0,0 +1,104 @@
+/*
+ * MatchMaker - create model matching files for VATSIM vPilot
+ *
+ *  MIT License
+ *
+ *  Copyright (c) 2021 Frank Kopp
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NON-INFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ *
+ */
+
+package rules
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/frankkopp/MatchMaker/internal/config"
+)
+
+func TestRuleSetDiffFile(t *testing.T) {
+	if err := config.Configuration.LoadFromString(testIni); err != nil {
+		t.Fatal(err)
+	}
+	rs := NewEngine(NewConfig(&config.Configuration)).Calculate(testLiveries())
+	file := filepath.Join(t.TempDir(), "rules.vmr")
+
+	// without a rules file all rules are added
+	diff, _, err := rs.DiffFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.Added) != len(rs.Rules()) || len(diff.Removed) != 0 || len(diff.Changed) != 0 {
+		t.Errorf("Diff() = %s, want all rules added", diff.Summary())
+	}
+
+	if err := rs.SaveToFile(file); err != nil {
+		t.Fatal(err)
+	}
+	diff, _, err = rs.DiffFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !diff.Empty() {
+		t.Errorf("Diff() = %s, want no changes to the saved rules", diff.Summary())
+	}
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vmr := strings.Replace(string(data), `<ModelMatchRule CallsignPrefix="CFG" TypeCode="B789" ModelName="B787 Condor" />`, "", 1)
+	vmr = strings.Replace(vmr, `ModelName="A320 Lufthansa" />`, `ModelName="Old Lufthansa" />`, 1)
+	vmr = strings.Replace(vmr, "</ModelMatchRuleSet>", `<ModelMatchRule CallsignPrefix="BAW" TypeCode="A320" ModelName="A320 British" />`+"\r\n</ModelMatchRuleSet>", 1)
+	if err := ioutil.WriteFile(file, []byte(vmr), 0644); err != nil {
+		t.Fatal(err)
+	}
+	diff, _, err = rs.DiffFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff.Summary() != "1 rules added, 1 removed, 1 changed" {
+		t.Fatalf("Summary() = %q", diff.Summary())
+	}
+	if c := diff.Added[0]; c.Icao != "CFG" || c.TypeCode != "B789" || c.BaseContainer != "Asobo_B787_10" || c.New != "B787 Condor" {
+		t.Errorf("Added = %+v", c)
+	}
+	if c := diff.Removed[0]; c.Icao != "BAW" || c.BaseContainer != "Asobo_A320_NEO" || c.Old != "A320 British" {
+		t.Errorf("Removed = %+v", c)
+	}
+	if c := diff.Changed[0]; c.Icao != "CLH" || c.TypeCode != "A20N" || c.Old != "Old Lufthansa" || c.New != "A320 Lufthansa" {
+		t.Errorf("Changed = %+v", c)
+	}
+
+	var report strings.Builder
+	if err := diff.WriteReport(&report); err != nil {
+		t.Fatal(err)
+	}
+	want := "Rules compared to " + file + ": 1 rules added, 1 removed, 1 changed\n" +
+		"ICAO BAW\n  BASE Asobo_A320_NEO\n    - A320: A320 British\n" +
+		"ICAO CFG\n  BASE Asobo_B787_10\n    + B789: B787 Condor\n" +
+		"ICAO CLH\n  BASE Asobo_A320_NEO\n    ~ A20N: Old Lufthansa => A320 Lufthansa\n"
+	if report.String() != want {
+		t.Errorf("WriteReport() =\n%s\nwant\n%s", report.String(), want)
+	}
+}
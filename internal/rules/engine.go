@@ -0,0 +1,373 @@
+/*
+ * MatchMaker - create model matching files for VATSIM vPilot
+ *
+ *  MIT License
+ *
+ *  Copyright (c) 2021 Frank Kopp
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NON-INFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ *
+ */
+
+package rules
+
+import (
+	"sort"
+
+	"github.com/frankkopp/MatchMaker/internal/config"
+	"github.com/frankkopp/MatchMaker/internal/livery"
+)
+
+// Config is a snapshot of the configuration the rules are calculated with.
+// It is copied from the ini when it is created so that later changes of the
+// configuration do not affect a running calculation.
+type Config struct {
+	DefaultTypes   map[string][]string // base container -> default liveries
+	TypeVariations map[string][]string // base container -> type codes
+	IcaoVariations []config.IcaoGroup  // groups of ICAOs using the same liveries - each ICAO is in one group only
+	IcaoFallbacks  map[string][]string // ICAO -> ICAOs whose liveries are used in order if it has none for a type code
+
+	// PreferExactTypes uses only the liveries of the base containers which match
+	// a type code best - see typeRank
+	PreferExactTypes bool
+	ExactTypes       map[string][]string // base container -> type codes it matches exactly
+
+	// SubtypePatterns limit a livery to some of the type codes of its base container - see liveryTypeCodes
+	SubtypePatterns []config.SubtypePattern
+
+	// weights repeat a title in a rule or drop it - see weight
+	CustomWeights    map[string]int // aircraft.cfg file -> weight of the custom data
+	TitleWeights     []config.TitleWeight
+	PackageWeights   map[string]int // package name -> weight
+	MaxTitlesPerRule int            // 0 for no limit
+
+	// the cross base fallback uses the liveries of an ICAO on the closest other
+	// base container for type codes it has no livery for - see closestBase
+	CrossBaseFallback bool
+	CrossBaseIcaos    map[string]bool // ICAO -> cross base fallback independent of CrossBaseFallback
+	TypeFamilies      []config.TypeFamily
+	BaseOrder         []string // base containers in the order of the type variations
+}
+
+// NewConfig takes a snapshot of the rules relevant sections of the configuration
+func NewConfig(c *config.Config) Config {
+	cfg := Config{
+		DefaultTypes:   readConfig(c.Ini.Section("defaultTypes")),
+		TypeVariations: map[string][]string{},
+		IcaoVariations: c.IcaoVariations(),
+		IcaoFallbacks:  readConfig(c.Ini.Section("icaoFallbacks")),
+
+		PreferExactTypes: c.PreferExactTypes(),
+		ExactTypes:       readConfig(c.Ini.Section("exactTypes")),
+		SubtypePatterns:  c.SubtypePatterns(),
+
+		CustomWeights:    map[string]int{},
+		TitleWeights:     c.TitleWeights(),
+		PackageWeights:   c.PackageWeights(),
+		MaxTitlesPerRule: c.MaxTitlesPerRule(),
+
+		CrossBaseFallback: c.CrossBaseFallback(),
+		CrossBaseIcaos:    c.CrossBaseIcaos(),
+		TypeFamilies:      c.TypeFamilies(),
+	}
+	if c.Custom != nil {
+		cfg.CustomWeights = c.Custom.Weights()
+	}
+	for _, section := range c.TypeVariationSections() {
+		for baseContainer, typeCodes := range readConfig(section) {
+			cfg.TypeVariations[baseContainer] = append(cfg.TypeVariations[baseContainer], typeCodes...)
+		}
+		for _, key := range section.Keys() {
+			cfg.BaseOrder = appendUnique(cfg.BaseOrder, key.Name())
+		}
+	}
+	return cfg
+}
+
+// Engine calculates rule sets from liveries with a fixed configuration. An
+// engine does not change its configuration or the liveries and can be used
+// concurrently.
+type Engine struct {
+	config Config
+}
+
+// NewEngine creates an engine for the configuration snapshot
+func NewEngine(cfg Config) *Engine {
+	return &Engine{config: cfg}
+}
+
+// Calculate creates the rules for all liveries which are included and for the
+// default liveries of each base container.
+func (e *Engine) Calculate(liveries []*livery.Livery) *RuleSet {
+	typeVariations, bases := e.prepare(liveries)
+	candidates, count := e.collect(liveries, typeVariations, bases, func(string) bool { return true })
+	models := e.buildModels(candidates, typeVariations)
+
+	// ICAOs without own liveries for a type code use the liveries of their fallbacks
+	fallbacks := e.addFallbacks(models)
+	// ICAOs with cross base fallback use their liveries of other base containers for the remaining type codes
+	crossBase := e.addCrossBase(candidates, models, bases, typeVariations)
+
+	return newRuleSet(models, bases, typeVariations, count, fallbacks, crossBase)
+}
+
+// prepare returns the type variations including the type codes of the base
+// aircraft and the configured base containers in alphabetical order
+func (e *Engine) prepare(liveries []*livery.Livery) (map[string][]string, []string) {
+	typeVariations := make(map[string][]string, len(e.config.TypeVariations))
+	for baseContainer, typeCodes := range e.config.TypeVariations {
+		typeVariations[baseContainer] = typeCodes
+	}
+	addBaseAircraftTypes(liveries, typeVariations)
+
+	bases := make([]string, 0, len(e.config.DefaultTypes))
+	for baseContainer := range e.config.DefaultTypes {
+		bases = append(bases, baseContainer)
+	}
+	sort.Strings(bases)
+	return typeVariations, bases
+}
+
+// collect returns the candidates map[ICAO][TypeCode][]titles with their base
+// container for the ICAOs to calculate - "" is the ICAO of the default rules.
+// Also returns the number of liveries used for rules.
+func (e *Engine) collect(liveries []*livery.Livery, typeVariations map[string][]string, bases []string, calculate func(icao string) bool) (map[string]map[string][]candidate, int) {
+	candidates := map[string]map[string][]candidate{}
+
+	// create default rules for each type variation
+	if calculate("") {
+		candidates[""] = map[string][]candidate{}
+		for _, baseContainer := range bases {
+			for _, typeCode := range typeVariations[baseContainer] {
+				for _, title := range e.config.DefaultTypes[baseContainer] {
+					candidates[""][typeCode] = append(candidates[""][typeCode], candidate{title, baseContainer, e.titleWeight(title, 1)})
+				}
+			}
+		}
+	}
+
+	// create an entry for each icao and type-code
+	// <ModelMatchRule CallsignPrefix="DLH" TypeCode="A380" ModelName="Boeing 747-8i Lufthansa" />
+	count := 0
+	for _, l := range liveries {
+		// skip all which are not used for rules - e.g. incomplete, disabled, duplicates
+		// or liveries with base containers which are not configured. A livery without
+		// ICAO is never used as "" is the ICAO of the default rules.
+		if !l.Included() || l.Icao == "" {
+			continue
+		}
+		count++
+		weight := -1 // only determined if the livery is used
+		for _, icao := range findIcaoVariations(l, e.config.IcaoVariations) {
+			if !calculate(icao) {
+				continue
+			}
+			if weight < 0 {
+				weight = e.weight(l)
+			}
+			if _, ok := candidates[icao]; !ok {
+				candidates[icao] = map[string][]candidate{}
+			}
+			for _, typeCode := range e.liveryTypeCodes(l, typeVariations[l.BaseContainer]) {
+				candidates[icao][typeCode] = append(candidates[icao][typeCode], candidate{l.Title, l.BaseContainer, weight})
+			}
+		}
+	}
+	return candidates, count
+}
+
+// buildModels returns the models map[ICAO][TypeCode][]titles of the candidates
+func (e *Engine) buildModels(candidates map[string]map[string][]candidate, typeVariations map[string][]string) map[string]map[string][]string {
+	models := make(map[string]map[string][]string, len(candidates))
+	for icao, types := range candidates {
+		models[icao] = make(map[string][]string, len(types))
+		for typeCode, list := range types {
+			if e.config.PreferExactTypes {
+				list = e.closestMatches(list, typeCode, typeVariations)
+			}
+			if titles := e.selectTitles(list); len(titles) > 0 {
+				models[icao][typeCode] = titles
+			}
+		}
+	}
+	return models
+}
+
+// newRuleSet creates the rules from the models ordered by ICAO, base container
+// and the type codes as configured. fallbacks and crossBase are the number of
+// type codes per ICAO which use a fallback.
+func newRuleSet(models map[string]map[string][]string, bases []string, typeVariations map[string][]string, liveries int, fallbacks, crossBase map[string]int) *RuleSet {
+	stats := Stats{Liveries: liveries}
+	icaos := make([]string, 0, len(models))
+	for icao, types := range models {
+		icaos = append(icaos, icao)
+		for _, titles := range types {
+			// this counts each livery for the same icao and type
+			stats.Mappings += len(titles)
+		}
+		stats.Fallbacks += fallbacks[icao]
+		stats.CrossBase += crossBase[icao]
+	}
+	sort.Strings(icaos)
+	rs := &RuleSet{bases: bases, models: models, fallbacks: fallbacks, crossBase: crossBase, xml: newXMLCache()}
+	for _, icao := range icaos {
+		if len(models[icao]) == 0 {
+			continue
+		}
+		if icao != "" {
+			stats.Icaos++
+		}
+		for _, baseContainer := range bases {
+			for _, typeCode := range typeVariations[baseContainer] {
+				titles := models[icao][typeCode]
+				if len(titles) == 0 {
+					continue
+				}
+				rs.rules = append(rs.rules, Rule{Icao: icao, TypeCode: typeCode, BaseContainer: baseContainer, Models: titles})
+				if icao == "" {
+					stats.DefaultRules++
+				} else {
+					stats.IcaoRules++
+				}
+			}
+		}
+	}
+	rs.stats = stats
+	return rs
+}
+
+// candidate is a livery title which could be used for a type code
+type candidate struct {
+	title         string
+	baseContainer string
+	weight        int
+}
+
+// closestMatches returns the candidates of the base containers which match the
+// type code best. Other base containers are only substitutes if there is no
+// closer livery.
+func (e *Engine) closestMatches(list []candidate, typeCode string, typeVariations map[string][]string) []candidate {
+	best := -1
+	for _, c := range list {
+		if rank := e.typeRank(c.baseContainer, typeCode, typeVariations); best < 0 || rank < best {
+			best = rank
+		}
+	}
+	var closest []candidate
+	for _, c := range list {
+		if e.typeRank(c.baseContainer, typeCode, typeVariations) == best {
+			closest = append(closest, c)
+		}
+	}
+	return closest
+}
+
+// typeRank returns how well the base container matches the type code - lower
+// is better. 0 if the type code is declared in [exactTypes] for the base
+// container, otherwise the position of the type code in its type variations
+// starting with 1.
+func (e *Engine) typeRank(baseContainer, typeCode string, typeVariations map[string][]string) int {
+	for _, t := range e.config.ExactTypes[baseContainer] {
+		if t == typeCode {
+			return 0
+		}
+	}
+	for i, t := range typeVariations[baseContainer] {
+		if t == typeCode {
+			return i + 1
+		}
+	}
+	return len(typeVariations[baseContainer]) + 1
+}
+
+// addFallbacks adds the liveries of the fallback ICAOs for the type codes an
+// ICAO of [icaoFallbacks] has no own liveries for. Returns the number of type
+// codes per ICAO which use a fallback.
+func (e *Engine) addFallbacks(models map[string]map[string][]string) map[string]int {
+	// the fallbacks only use the own liveries of an ICAO
+	own := make(map[string]map[string][]string, len(models))
+	for icao, types := range models {
+		own[icao] = make(map[string][]string, len(types))
+		for typeCode, titles := range types {
+			own[icao][typeCode] = titles
+		}
+	}
+	icaos := make([]string, 0, len(e.config.IcaoFallbacks))
+	for icao := range e.config.IcaoFallbacks {
+		icaos = append(icaos, icao)
+	}
+	sort.Strings(icaos)
+
+	added := map[string]int{}
+	for _, icao := range icaos {
+		typeCodes := map[string]bool{}
+		visited := map[string]bool{}
+		e.collectTypeCodes(own, icao, typeCodes, visited)
+		for typeCode := range typeCodes {
+			if len(own[icao][typeCode]) > 0 {
+				continue
+			}
+			titles := e.fallback(own, icao, typeCode, map[string]bool{})
+			if titles == nil {
+				continue
+			}
+			if models[icao] == nil {
+				models[icao] = map[string][]string{}
+			}
+			models[icao][typeCode] = titles
+			added[icao]++
+		}
+	}
+	return added
+}
+
+// collectTypeCodes adds the type codes with liveries of the ICAO and all its fallbacks
+func (e *Engine) collectTypeCodes(own map[string]map[string][]string, icao string, typeCodes, visited map[string]bool) {
+	if visited[icao] {
+		return
+	}
+	visited[icao] = true
+	for typeCode, titles := range own[icao] {
+		if len(titles) > 0 {
+			typeCodes[typeCode] = true
+		}
+	}
+	for _, fallback := range e.config.IcaoFallbacks[icao] {
+		e.collectTypeCodes(own, fallback, typeCodes, visited)
+	}
+}
+
+// fallback returns the liveries of the first fallback of the ICAO which has own
+// liveries for the type code. The fallbacks of a fallback are tried before the
+// next fallback. Cycles in [icaoFallbacks] are ignored.
+func (e *Engine) fallback(own map[string]map[string][]string, icao, typeCode string, visited map[string]bool) []string {
+	visited[icao] = true
+	for _, fb := range e.config.IcaoFallbacks[icao] {
+		if visited[fb] {
+			continue
+		}
+		if titles := own[fb][typeCode]; len(titles) > 0 {
+			return titles
+		}
+		if titles := e.fallback(own, fb, typeCode, visited); titles != nil {
+			return titles
+		}
+	}
+	return nil
+}
@@ -0,0 +1,299 @@
+/*
+ * MatchMaker - create model matching files for VATSIM vPilot
+ *
+ *  MIT License
+ *
+ *  Copyright (c) 2021 Frank Kopp
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NON-INFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ *
+ */
+
+package rules
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/frankkopp/MatchMaker/internal/config"
+	"github.com/frankkopp/MatchMaker/internal/livery"
+)
+
+const testIni = `
+[defaultTypes]
+Asobo_A320_NEO = Airbus A320 Neo Asobo
+Asobo_B787_10  = Boeing 787-10 Asobo
+Asobo_CJ4      = Cessna CJ4 Citation Asobo
+[typeVariations]
+Asobo_A320_NEO = A20N,A320
+Asobo_B787_10  = B789
+[icaoVariations]
+Lufthansa = DLH,CLH
+`
+
+func testLiveries() []*livery.Livery {
+	return []*livery.Livery{
+		{Title: "A320 Lufthansa", Icao: "DLH", BaseContainer: "Asobo_A320_NEO"},
+		{Title: "A320 Condor", Icao: "CFG", BaseContainer: "Asobo_A320_NEO"},
+		{Title: "B787 Condor", Icao: "CFG", BaseContainer: "Asobo_B787_10"},
+		{Title: "CJ4 Test", Icao: "TST", BaseContainer: "Asobo_CJ4", BaseAircraft: &livery.Aircraft{IcaoTypeDesignator: "C25C"}},
+		{Title: "A320 Duplicate", Icao: "DUP", BaseContainer: "Asobo_A320_NEO", Reasons: []livery.Reason{livery.ReasonDuplicate}},
+	}
+}
+
+func TestEngineCalculate(t *testing.T) {
+	if err := config.Configuration.LoadFromString(testIni); err != nil {
+		t.Fatal(err)
+	}
+	cfg := NewConfig(&config.Configuration)
+	// later changes of the configuration do not change the snapshot
+	config.Configuration.Ini.Section("typeVariations").Key("Asobo_B787_10").SetValue("B788")
+
+	rs := NewEngine(cfg).Calculate(testLiveries())
+	want := Stats{Mappings: 12, DefaultRules: 4, IcaoRules: 8, Icaos: 4, Liveries: 4}
+	if rs.Stats() != want {
+		t.Errorf("Stats() = %+v, want %+v", rs.Stats(), want)
+	}
+	if got := rs.Models("CLH", "A320"); !reflect.DeepEqual(got, []string{"A320 Lufthansa"}) {
+		t.Errorf("Models(CLH, A320) = %v", got)
+	}
+	if got := rs.Models("", "C25C"); !reflect.DeepEqual(got, []string{"Cessna CJ4 Citation Asobo"}) {
+		t.Errorf("Models(\"\", C25C) = %v", got)
+	}
+	if got := rs.Models("DUP", "A320"); got != nil {
+		t.Errorf("Models(DUP, A320) = %v, want no rule for excluded livery", got)
+	}
+	if len(cfg.TypeVariations["Asobo_CJ4"]) != 0 {
+		t.Errorf("Calculate() changed the configuration: %v", cfg.TypeVariations)
+	}
+
+	// the rules are ordered by ICAO with the defaults first and can't be changed from outside
+	rules := rs.Rules()
+	if len(rules) != 12 || !rules[0].Default() || rules[4].Icao != "CFG" || rules[len(rules)-1].Icao != "TST" {
+		t.Errorf("Rules() = %+v", rules)
+	}
+	rules[0].Models[0] = "changed"
+	if rs.Rules()[0].Models[0] == "changed" {
+		t.Errorf("Rules() returned the internal models")
+	}
+
+	xml, n := rs.GenerateXML()
+	if n != 8 {
+		t.Errorf("GenerateXML() created %d rules, want 8", n)
+	}
+	for _, line := range []string{
+		"<!-- BASE: Asobo_B787_10 -->\r\n<ModelMatchRule TypeCode=\"B789\" ModelName=\"Boeing 787-10 Asobo\" />\r\n",
+		"<!-- ICAO: CLH -->\r\n<!-- BASE: Asobo_A320_NEO -->\r\n<ModelMatchRule CallsignPrefix=\"CLH\" TypeCode=\"A20N\" ModelName=\"A320 Lufthansa\" />\r\n",
+		"<ModelMatchRule CallsignPrefix=\"TST\" TypeCode=\"C25C\" ModelName=\"CJ4 Test\" />\r\n",
+	} {
+		if !strings.Contains(xml, line) {
+			t.Errorf("GenerateXML() has no %q", line)
+		}
+	}
+	if strings.Contains(xml, "DUP") {
+		t.Errorf("GenerateXML() has rules for excluded liveries")
+	}
+}
+
+func TestEngineLiveryWithoutIcao(t *testing.T) {
+	if err := config.Configuration.LoadFromString(testIni); err != nil {
+		t.Fatal(err)
+	}
+	// an included livery without ICAO must not be added to the default rules
+	liveries := append(testLiveries(), &livery.Livery{Title: "Some Livery", BaseContainer: "Asobo_A320_NEO"})
+	rs := NewEngine(NewConfig(&config.Configuration)).Calculate(liveries)
+	if got := rs.Models("", "A320"); !reflect.DeepEqual(got, []string{"Airbus A320 Neo Asobo"}) {
+		t.Errorf("Models(\"\", A320) = %v, want only the default livery", got)
+	}
+	want := Stats{Mappings: 12, DefaultRules: 4, IcaoRules: 8, Icaos: 4, Liveries: 4}
+	if rs.Stats() != want {
+		t.Errorf("Stats() = %+v, want %+v", rs.Stats(), want)
+	}
+}
+
+func TestCalculateRulesWrapper(t *testing.T) {
+	if err := config.Configuration.LoadFromString(testIni); err != nil {
+		t.Fatal(err)
+	}
+	CalculateRules(testLiveries())
+	if Counter != 12 || !Dirty {
+		t.Errorf("Counter = %d, Dirty = %v", Counter, Dirty)
+	}
+	if !reflect.DeepEqual(Rules["default"]["A20N"], []string{"Airbus A320 Neo Asobo"}) || len(Rules["CFG"]) != 3 {
+		t.Errorf("Rules = %v", Rules)
+	}
+	output, n := GenerateXML()
+	xml, want := NewEngine(NewConfig(&config.Configuration)).Calculate(testLiveries()).GenerateXML()
+	if output.String() != xml || n != want {
+		t.Errorf("GenerateXML() differs from the rule set")
+	}
+}
+
+func TestEnginePreferExactTypes(t *testing.T) {
+	const ini = `
+[defaultTypes]
+Asobo_A320_NEO = Airbus A320 Neo Asobo
+Boeing_737     = Boeing 737 Default
+[typeVariations]
+Asobo_A320_NEO = A20N,A320,B738
+Boeing_737     = B737,B738
+`
+	liveries := []*livery.Livery{
+		{Title: "A320 Lufthansa", Icao: "DLH", BaseContainer: "Asobo_A320_NEO"},
+		{Title: "B737 Lufthansa", Icao: "DLH", BaseContainer: "Boeing_737"},
+		{Title: "A320 Condor", Icao: "CFG", BaseContainer: "Asobo_A320_NEO"},
+	}
+	tests := []struct {
+		name  string
+		rules string
+		dlh   []string
+		def   []string
+	}{
+		{"all substitutes", "", []string{"A320 Lufthansa", "B737 Lufthansa"}, []string{"Airbus A320 Neo Asobo", "Boeing 737 Default"}},
+		{"ranked by position", "[rules]\npreferExactTypes = true\n", []string{"B737 Lufthansa"}, []string{"Boeing 737 Default"}},
+		{"declared", "[rules]\npreferExactTypes = true\n[exactTypes]\nAsobo_A320_NEO = B738\n", []string{"A320 Lufthansa"}, []string{"Airbus A320 Neo Asobo"}},
+	}
+	for _, tt := range tests {
+		if err := config.Configuration.LoadFromString(ini + tt.rules); err != nil {
+			t.Fatal(err)
+		}
+		rs := NewEngine(NewConfig(&config.Configuration)).Calculate(liveries)
+		if got := rs.Models("DLH", "B738"); !reflect.DeepEqual(got, tt.dlh) {
+			t.Errorf("%s: Models(DLH, B738) = %v, want %v", tt.name, got, tt.dlh)
+		}
+		if got := rs.Models("", "B738"); !reflect.DeepEqual(got, tt.def) {
+			t.Errorf("%s: Models(\"\", B738) = %v, want %v", tt.name, got, tt.def)
+		}
+		// the substitute is used if the airline has no closer livery
+		if got := rs.Models("CFG", "B738"); !reflect.DeepEqual(got, []string{"A320 Condor"}) {
+			t.Errorf("%s: Models(CFG, B738) = %v", tt.name, got)
+		}
+		if got := rs.Models("DLH", "A320"); !reflect.DeepEqual(got, []string{"A320 Lufthansa"}) {
+			t.Errorf("%s: Models(DLH, A320) = %v", tt.name, got)
+		}
+	}
+}
+
+func TestEngineIcaoFallbacks(t *testing.T) {
+	const ini = `
+[defaultTypes]
+Asobo_A320_NEO = Airbus A320 Neo Asobo
+Asobo_B787_10  = Boeing 787-10 Asobo
+Asobo_CJ4      = Cessna CJ4 Citation Asobo
+[typeVariations]
+Asobo_A320_NEO = A320
+Asobo_B787_10  = B789
+Asobo_CJ4      = C25C
+[icaoFallbacks]
+CLH = DLH
+LHX = CLH,CFG
+BA  = BAW
+DLH = LHX
+`
+	liveries := []*livery.Livery{
+		{Title: "A320 Lufthansa", Icao: "DLH", BaseContainer: "Asobo_A320_NEO"},
+		{Title: "B787 Lufthansa", Icao: "DLH", BaseContainer: "Asobo_B787_10"},
+		{Title: "A320 CityLine", Icao: "CLH", BaseContainer: "Asobo_A320_NEO"},
+		{Title: "CJ4 Condor", Icao: "CFG", BaseContainer: "Asobo_CJ4"},
+		{Title: "B787 British", Icao: "BAW", BaseContainer: "Asobo_B787_10"},
+	}
+	if err := config.Configuration.LoadFromString(ini); err != nil {
+		t.Fatal(err)
+	}
+	rs := NewEngine(NewConfig(&config.Configuration)).Calculate(liveries)
+	tests := []struct {
+		icao, typeCode string
+		want           []string
+	}{
+		{"CLH", "A320", []string{"A320 CityLine"}},  // own liveries first
+		{"CLH", "B789", []string{"B787 Lufthansa"}}, // fallback for the missing type code
+		{"CLH", "C25C", []string{"CJ4 Condor"}},     // CLH -> DLH -> LHX -> CFG
+		{"LHX", "A320", []string{"A320 CityLine"}},  // hierarchical
+		{"LHX", "B789", []string{"B787 Lufthansa"}}, // fallbacks of a fallback before the next fallback
+		{"LHX", "C25C", []string{"CJ4 Condor"}},
+		{"BA", "B789", []string{"B787 British"}}, // alias
+		{"BAW", "B789", []string{"B787 British"}},
+		{"DLH", "A320", []string{"A320 Lufthansa"}},
+		{"DLH", "C25C", []string{"CJ4 Condor"}}, // cycle DLH -> LHX -> CLH -> DLH is ignored
+	}
+	for _, tt := range tests {
+		if got := rs.Models(tt.icao, tt.typeCode); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Models(%s, %s) = %v, want %v", tt.icao, tt.typeCode, got, tt.want)
+		}
+	}
+	if rs.Stats().Fallbacks != 7 {
+		t.Errorf("Stats().Fallbacks = %d, want 7", rs.Stats().Fallbacks)
+	}
+	xml, _ := rs.GenerateXML()
+	if !strings.Contains(xml, "<ModelMatchRule CallsignPrefix=\"BA\" TypeCode=\"B789\" ModelName=\"B787 British\" />") {
+		t.Errorf("GenerateXML() has no rule for the alias BA")
+	}
+}
+
+func TestEngineIcaoVariationConflicts(t *testing.T) {
+	const ini = `
+[defaultTypes]
+Asobo_A320_NEO = Airbus A320 Neo Asobo
+[typeVariations]
+Asobo_A320_NEO = A320
+[icaoVariations]
+BritishAirways = BAW,BA,SHT
+Shuttle        = SHT,BA
+Cityflyer      = CFE,SHT
+`
+	liveries := []*livery.Livery{
+		{Title: "A320 British", Icao: "BAW", BaseContainer: "Asobo_A320_NEO"},
+		{Title: "A320 Shuttle", Icao: "SHT", BaseContainer: "Asobo_A320_NEO"},
+		{Title: "A320 Cityflyer", Icao: "CFE", BaseContainer: "Asobo_A320_NEO"},
+	}
+	tests := []struct {
+		name        string
+		resolutions string
+		ba, sht     []string
+		resolved    bool
+	}{
+		{"first group", "", []string{"A320 British", "A320 Shuttle"}, []string{"A320 British", "A320 Shuttle"}, false},
+		{"declared", "[icaoResolutions]\nBA = BritishAirways\nSHT = Cityflyer\n", []string{"A320 British"}, []string{"A320 Shuttle", "A320 Cityflyer"}, true},
+		{"unknown group", "[icaoResolutions]\nBA = Unknown\nSHT = Unknown\n", []string{"A320 British", "A320 Shuttle"}, []string{"A320 British", "A320 Shuttle"}, false},
+	}
+	for _, tt := range tests {
+		if err := config.Configuration.LoadFromString(ini + tt.resolutions); err != nil {
+			t.Fatal(err)
+		}
+		conflicts := config.Configuration.IcaoConflicts()
+		if len(conflicts) != 2 || conflicts[0].Icao != "BA" || conflicts[1].Icao != "SHT" ||
+			!reflect.DeepEqual(conflicts[1].Groups, []string{"BritishAirways", "Shuttle", "Cityflyer"}) {
+			t.Fatalf("%s: IcaoConflicts() = %+v", tt.name, conflicts)
+		}
+		if conflicts[0].Resolved() != tt.resolved || !strings.Contains(conflicts[1].String(), "BritishAirways, Shuttle, Cityflyer") {
+			t.Errorf("%s: conflict %q resolved = %v", tt.name, conflicts[1], conflicts[1].Resolved())
+		}
+		// the result is the same for each calculation
+		for i := 0; i < 10; i++ {
+			rs := NewEngine(NewConfig(&config.Configuration)).Calculate(liveries)
+			if got := rs.Models("BA", "A320"); !reflect.DeepEqual(got, tt.ba) {
+				t.Fatalf("%s: Models(BA, A320) = %v, want %v", tt.name, got, tt.ba)
+			}
+			if got := rs.Models("SHT", "A320"); !reflect.DeepEqual(got, tt.sht) {
+				t.Fatalf("%s: Models(SHT, A320) = %v, want %v", tt.name, got, tt.sht)
+			}
+		}
+	}
+}
@@ -0,0 +1,299 @@
+/*
+ * MatchMaker - create model matching files for VATSIM vPilot
+ *
+ *  MIT License
+ *
+ *  Copyright (c) 2021 Frank Kopp
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NON-INFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ *
+ */
+
+package rules
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// VMRIssue is a problem with a rule of an imported VMR file - the rule is
+// ignored or only partially used
+type VMRIssue struct {
+	File    string
+	Line    int
+	Message string
+}
+
+func (i VMRIssue) String() string {
+	return fmt.Sprintf("%s:%d: %s", i.File, i.Line, i.Message)
+}
+
+// ParseVMR reads a VMR file into a rule set. Malformed rules - e.g. without
+// TypeCode or ModelName - are skipped and returned as issues with their line
+// number. Returns an error if the data is not well formed XML or not a
+// ModelMatchRuleSet.
+func ParseVMR(r io.Reader, file string) (*ModelMatchRuleSet, []VMRIssue, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	// line returns the line of the offset in data
+	line := func(offset int64) int {
+		return bytes.Count(data[:offset], []byte("\n")) + 1
+	}
+
+	set := &ModelMatchRuleSet{}
+	var issues []VMRIssue
+	d := xml.NewDecoder(bytes.NewReader(data))
+	depth := 0
+	rootFound := false
+	for {
+		// the offset before reading the token is the start of the token
+		offset := d.InputOffset()
+		token, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var syntaxErr *xml.SyntaxError
+			if errors.As(err, &syntaxErr) {
+				return nil, issues, fmt.Errorf("%s:%d: %s", file, syntaxErr.Line, syntaxErr.Msg)
+			}
+			return nil, issues, fmt.Errorf("%s:%d: %v", file, line(d.InputOffset()), err)
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			depth++
+			switch {
+			case depth == 1 && t.Name.Local != "ModelMatchRuleSet":
+				return nil, issues, fmt.Errorf("%s:%d: root element is %s and not ModelMatchRuleSet", file, line(offset), t.Name.Local)
+			case depth == 1:
+				rootFound = true
+			case depth == 2 && t.Name.Local == "ModelMatchRule":
+				rule, ruleIssues := parseRule(t)
+				for _, msg := range ruleIssues {
+					issues = append(issues, VMRIssue{file, line(offset), msg})
+				}
+				if rule != nil {
+					set.AddRule(*rule)
+				}
+			case depth == 2:
+				issues = append(issues, VMRIssue{file, line(offset), "unknown element " + t.Name.Local + " ignored"})
+			}
+			if depth > 1 {
+				// rules have no content
+				if err := d.Skip(); err != nil {
+					return nil, issues, fmt.Errorf("%s:%d: %v", file, line(d.InputOffset()), err)
+				}
+				depth--
+			}
+		case xml.EndElement:
+			depth--
+		}
+	}
+	if !rootFound {
+		return nil, issues, fmt.Errorf("%s: no ModelMatchRuleSet found", file)
+	}
+	return set, issues, nil
+}
+
+// parses the attributes of a ModelMatchRule - returns nil if the rule can't be used
+func parseRule(start xml.StartElement) (*ModelMatchRule, []string) {
+	var rule ModelMatchRule
+	var issues []string
+	for _, attr := range start.Attr {
+		switch attr.Name.Local {
+		case "CallsignPrefix":
+			rule.CallsignPrefix = strings.TrimSpace(attr.Value)
+		case "TypeCode":
+			rule.TypeCode = strings.TrimSpace(attr.Value)
+		case "ModelName":
+			rule.ModelName = strings.Join(splitModels(attr.Value), "//")
+		default:
+			issues = append(issues, "unknown attribute "+attr.Name.Local+" ignored")
+		}
+	}
+	switch {
+	case rule.TypeCode == "":
+		return nil, append(issues, "rule without TypeCode ignored")
+	case rule.ModelName == "":
+		return nil, append(issues, "rule without ModelName ignored")
+	}
+	return &rule, issues
+}
+
+// splitModels returns the titles of a ModelName without empty titles
+func splitModels(modelName string) []string {
+	var models []string
+	for _, m := range strings.Split(modelName, "//") {
+		if m = strings.TrimSpace(m); m != "" {
+			models = append(models, m)
+		}
+	}
+	return models
+}
+
+// LoadVMRFiles reads all VMR files and returns their rules in the order of the files
+func LoadVMRFiles(files []string) ([]ModelMatchRule, []VMRIssue, error) {
+	var rules []ModelMatchRule
+	var issues []VMRIssue
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, issues, err
+		}
+		set, fileIssues, err := ParseVMR(bytes.NewReader(data), file)
+		issues = append(issues, fileIssues...)
+		if err != nil {
+			return nil, issues, err
+		}
+		rules = append(rules, set.Rules...)
+	}
+	return rules, issues, nil
+}
+
+// MergeFiles loads the VMR files and merges their rules into a new rule set
+// with the named merge strategy
+func (rs *RuleSet) MergeFiles(files []string, strategyName string) (*RuleSet, []VMRIssue, error) {
+	strategy, err := ParseMergeStrategy(strategyName)
+	if err != nil {
+		return nil, nil, err
+	}
+	imported, issues, err := LoadVMRFiles(files)
+	if err != nil {
+		return nil, issues, err
+	}
+	return rs.Merge(imported, strategy), issues, nil
+}
+
+// MergeStrategy decides which ModelName is used if a generated and an imported
+// rule have the same CallsignPrefix and TypeCode
+type MergeStrategy int
+
+const (
+	MergeGenerated MergeStrategy = iota // the generated rule wins
+	MergeImported                       // the imported rule wins
+	MergeUnion                          // the liveries of both rules are used
+)
+
+var mergeStrategyNames = [...]string{"generated", "imported", "union"}
+
+func (s MergeStrategy) String() string {
+	return mergeStrategyNames[s]
+}
+
+// ParseMergeStrategy returns the merge strategy for its name
+func ParseMergeStrategy(name string) (MergeStrategy, error) {
+	for i, n := range mergeStrategyNames {
+		if strings.EqualFold(strings.TrimSpace(name), n) {
+			return MergeStrategy(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown merge strategy %q - use %s", name, strings.Join(mergeStrategyNames[:], ", "))
+}
+
+// Merge returns a new rule set with the imported rules merged into the rules.
+// Imported rules for a CallsignPrefix and TypeCode without generated rule are
+// added after the generated rules of their ICAO.
+func (rs *RuleSet) Merge(imported []ModelMatchRule, strategy MergeStrategy) *RuleSet {
+	type key struct{ icao, typeCode string }
+	importedModels := map[key][]string{}
+	var importedKeys []key
+	for _, r := range imported {
+		k := key{r.CallsignPrefix, r.TypeCode}
+		if _, found := importedModels[k]; !found {
+			importedKeys = append(importedKeys, k)
+		}
+		importedModels[k] = appendUnique(importedModels[k], splitModels(r.ModelName)...)
+	}
+
+	merged := &RuleSet{bases: rs.bases, models: map[string]map[string][]string{}, fallbacks: rs.fallbacks, crossBase: rs.crossBase, xml: newXMLCache()}
+	for icao, types := range rs.models {
+		merged.models[icao] = map[string][]string{}
+		for typeCode, models := range types {
+			merged.models[icao][typeCode] = models
+		}
+	}
+	for _, k := range importedKeys {
+		models, generated := merged.models[k.icao][k.typeCode]
+		switch {
+		case !generated || len(models) == 0 || strategy == MergeImported:
+			models = importedModels[k]
+		case strategy == MergeUnion:
+			models = appendUnique(append([]string(nil), models...), importedModels[k]...)
+		}
+		if merged.models[k.icao] == nil {
+			merged.models[k.icao] = map[string][]string{}
+		}
+		merged.models[k.icao][k.typeCode] = models
+	}
+
+	for _, r := range rs.rules {
+		r.Models = merged.models[r.Icao][r.TypeCode]
+		merged.rules = append(merged.rules, r)
+	}
+	for _, k := range importedKeys {
+		if len(rs.models[k.icao][k.typeCode]) == 0 {
+			merged.rules = append(merged.rules, Rule{Icao: k.icao, TypeCode: k.typeCode, Models: merged.models[k.icao][k.typeCode]})
+		}
+	}
+	// keeps the generated rules of an ICAO before the imported
+	sort.SliceStable(merged.rules, func(i, j int) bool { return merged.rules[i].Icao < merged.rules[j].Icao })
+
+	merged.stats = Stats{Liveries: rs.stats.Liveries, Imported: len(importedKeys), Fallbacks: rs.stats.Fallbacks, CrossBase: rs.stats.CrossBase}
+	for icao, types := range merged.models {
+		for _, models := range types {
+			merged.stats.Mappings += len(models)
+		}
+		if icao != "" && len(types) > 0 {
+			merged.stats.Icaos++
+		}
+	}
+	for _, r := range merged.rules {
+		if r.Default() {
+			merged.stats.DefaultRules++
+		} else {
+			merged.stats.IcaoRules++
+		}
+	}
+	return merged
+}
+
+// appends the values which are not yet part of the list
+func appendUnique(list []string, values ...string) []string {
+	for _, v := range values {
+		found := false
+		for _, l := range list {
+			if l == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, v)
+		}
+	}
+	return list
+}
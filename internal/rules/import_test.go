@@ -0,0 +1,139 @@
+/*
+ * MatchMaker - create model matching files for VATSIM vPilot
+ *
+ *  MIT License
+ *
+ *  Copyright (c) 2021 Frank Kopp
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NON-INFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ *
+ */
+
+package rules
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/frankkopp/MatchMaker/internal/config"
+)
+
+const testVMR = `<?xml version="1.0" encoding="UTF-8"?>
+<ModelMatchRuleSet>
+<!-- hand written -->
+<ModelMatchRule CallsignPrefix="DLH" TypeCode="A320" ModelName="Old Lufthansa//A320 Lufthansa" />
+<ModelMatchRule CallsignPrefix="BAW" TypeCode="A320" ModelName="A320 British" />
+<ModelMatchRule CallsignPrefix="EZY" ModelName="A320 EasyJet" />
+<ModelMatchRule CallsignPrefix="EZY" TypeCode="A320"
+                ModelName="" />
+<ModelMatchRule CallsignPrefix="DLH" TypeCode="A20N" ModelName="Old A20N Lufthansa" Remark="x" />
+<Rule TypeCode="A320" />
+<ModelMatchRule TypeCode="B789" ModelName="Imported Default" />
+</ModelMatchRuleSet>
+`
+
+func TestParseVMR(t *testing.T) {
+	set, issues, err := ParseVMR(strings.NewReader(testVMR), "test.vmr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(set.Rules) != 4 {
+		t.Errorf("found %d rules, want 4: %+v", len(set.Rules), set.Rules)
+	}
+	var got []string
+	for _, issue := range issues {
+		got = append(got, issue.String())
+	}
+	want := []string{
+		"test.vmr:6: rule without TypeCode ignored",
+		"test.vmr:7: rule without ModelName ignored",
+		"test.vmr:9: unknown attribute Remark ignored",
+		"test.vmr:10: unknown element Rule ignored",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("issues = %q, want %q", got, want)
+	}
+
+	for _, data := range []string{
+		"<ModelMatchRuleSet>\n<ModelMatchRule TypeCode=\"A320\" ModelName=\"A & B\" />\n</ModelMatchRuleSet>",
+		"<ModelMatchRuleSet>\n<ModelMatchRule TypeCode=\"A320\" ModelName=\"A\" />\n",
+		"<Rules>\n</Rules>",
+		"",
+	} {
+		if _, _, err := ParseVMR(strings.NewReader(data), "broken.vmr"); err == nil || !strings.HasPrefix(err.Error(), "broken.vmr") {
+			t.Errorf("ParseVMR(%q) error = %v, want error with file name", data, err)
+		}
+	}
+	if _, _, err := ParseVMR(strings.NewReader("<ModelMatchRuleSet>\n<ModelMatchRule TypeCode=\"A320\" ModelName=\"A & B\" />\n</ModelMatchRuleSet>"), "broken.vmr"); err == nil || !strings.HasPrefix(err.Error(), "broken.vmr:2:") {
+		t.Errorf("ParseVMR() error = %v, want line 2", err)
+	}
+}
+
+func TestRuleSetMerge(t *testing.T) {
+	if err := config.Configuration.LoadFromString(testIni); err != nil {
+		t.Fatal(err)
+	}
+	generated := NewEngine(NewConfig(&config.Configuration)).Calculate(testLiveries())
+	set, _, err := ParseVMR(strings.NewReader(testVMR), "test.vmr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		strategy MergeStrategy
+		dlh      []string
+		def      []string
+	}{
+		{MergeGenerated, []string{"A320 Lufthansa"}, []string{"Boeing 787-10 Asobo"}},
+		{MergeImported, []string{"Old Lufthansa", "A320 Lufthansa"}, []string{"Imported Default"}},
+		{MergeUnion, []string{"A320 Lufthansa", "Old Lufthansa"}, []string{"Boeing 787-10 Asobo", "Imported Default"}},
+	}
+	for _, tt := range tests {
+		merged := generated.Merge(set.Rules, tt.strategy)
+		if got := merged.Models("DLH", "A320"); !reflect.DeepEqual(got, tt.dlh) {
+			t.Errorf("%s: Models(DLH, A320) = %v, want %v", tt.strategy, got, tt.dlh)
+		}
+		if got := merged.Models("", "B789"); !reflect.DeepEqual(got, tt.def) {
+			t.Errorf("%s: Models(\"\", B789) = %v, want %v", tt.strategy, got, tt.def)
+		}
+		// rules without generated rule are always imported
+		if got := merged.Models("BAW", "A320"); !reflect.DeepEqual(got, []string{"A320 British"}) {
+			t.Errorf("%s: Models(BAW, A320) = %v", tt.strategy, got)
+		}
+		stats := merged.Stats()
+		if stats.Imported != 4 || stats.Icaos != 5 || stats.IcaoRules != generated.Stats().IcaoRules+1 {
+			t.Errorf("%s: Stats() = %+v", tt.strategy, stats)
+		}
+		xml, err := merged.ValidXML()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(xml, "<!-- ICAO: BAW -->\r\n<!-- BASE: Asobo_A320_NEO -->\r\n<!-- BASE: Asobo_B787_10 -->\r\n<!-- BASE: Asobo_CJ4 -->\r\n"+
+			"<!-- IMPORTED -->\r\n<ModelMatchRule CallsignPrefix=\"BAW\" TypeCode=\"A320\" ModelName=\"A320 British\" />\r\n") {
+			t.Errorf("%s: imported rule not in XML:\n%s", tt.strategy, xml)
+		}
+	}
+	// merging does not change the generated rules
+	if got := generated.Models("DLH", "A320"); !reflect.DeepEqual(got, []string{"A320 Lufthansa"}) || generated.Stats().Imported != 0 {
+		t.Errorf("Merge() changed the generated rules: %v", got)
+	}
+	if _, err := ParseMergeStrategy("newest"); err == nil {
+		t.Errorf("ParseMergeStrategy(newest) = nil, want error")
+	}
+}
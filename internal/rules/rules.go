@@ -25,100 +25,109 @@
  *
  */
 
-// Package rules manages rules as a map of map of a list of liveries
-// This gives convenient access to the liveries for each icao and type-code
-// while implicitly avoiding doublets
+// Package rules calculates the model matching rules for vPilot from the
+// liveries. An Engine takes a snapshot of the configuration and creates an
+// immutable RuleSet. The package level variables and functions are thin
+// wrappers around the engine for the code which has not been migrated yet.
 package rules
 
 import (
-	"fmt"
+	"log"
 	"sort"
 	"strings"
+	"sync"
 
 	"github.com/frankkopp/MatchMaker/internal/config"
 	"github.com/frankkopp/MatchMaker/internal/livery"
-	"github.com/frankkopp/MatchMaker/internal/util"
 	"gopkg.in/ini.v1"
 )
 
 var (
-
-	// Rules map[ICAO][TypeCode][]liveries
+	// Rules map[ICAO][TypeCode][]liveries - "default" is the ICAO of the default rules
+	//
+	// Deprecated: use the RuleSet returned by Engine.Calculate
 	Rules = map[string]map[string][]string{}
 
+	// Counter is the number of mappings of the last calculation
+	//
+	// Deprecated: use RuleSet.Stats
 	Counter = 0
 	Dirty   = false
 
 	DefaultTypes   map[string][]string
 	TypeVariations map[string][]string
 	IcaoVariations map[string][]string
+
+	// current is the rule set of the last CalculateRules call
+	current = &RuleSet{}
+	mu      sync.Mutex
 )
 
 // CalculateRules (re-)calculates the rules based on current configuration and livery data.
+// The rules of the configured import files are merged into the rules.
 // Will be stored in rules.Rules
+//
+// Deprecated: use NewEngine(NewConfig(&config.Configuration)).Calculate(liveries)
 func CalculateRules(liveries []*livery.Livery) {
-	// clear previous calculation
-	Counter = 0
-	Rules = map[string]map[string][]string{}
-
-	// read and build config data structures
-	// do this for every run to use the latest configuration
-	DefaultTypes = readConfig(config.Configuration.Ini.Section("defaultTypes"))
-	TypeVariations = readConfig(config.Configuration.Ini.Section("typeVariations"))
-	IcaoVariations = readConfig(config.Configuration.Ini.Section("icaoVariations"))
-
-	// create default rules for each type variation
-	// add ICAO and all variations to coreRules
-	// uses "default" as a special icao
-	Rules["default"] = map[string][]string{}
-	for baseContainer := range DefaultTypes {
-		for _, typeVariation := range TypeVariations[baseContainer] {
-			Rules["default"][typeVariation] = append(Rules["default"][typeVariation], DefaultTypes[baseContainer]...)
-			Counter++
+	cfg := NewConfig(&config.Configuration)
+	rs := NewEngine(cfg).Calculate(liveries)
+	if files := config.Configuration.ImportFiles(); len(files) > 0 {
+		merged, issues, err := rs.MergeFiles(files, config.Configuration.MergeStrategy())
+		for _, issue := range issues {
+			log.Print(issue)
+		}
+		if err != nil {
+			log.Printf("Rules not imported: %v", err)
+		} else {
+			rs = merged
 		}
 	}
 
-	// create an entry for each icao and type-code
-	// <ModelMatchRule CallsignPrefix="DLH" TypeCode="A380" ModelName="Boeing 747-8i Lufthansa" />
-	for _, cLivery := range liveries {
-		// skip all which are not to process or invalid
-		if !(cLivery.Process && cLivery.Complete) {
-			continue
+	mu.Lock()
+	defer mu.Unlock()
+	current = rs
+	Counter = rs.Stats().Mappings
+	Rules = map[string]map[string][]string{"default": {}}
+	for _, r := range rs.rules {
+		icao := r.Icao
+		if r.Default() {
+			icao = "default"
+		}
+		if _, ok := Rules[icao]; !ok {
+			Rules[icao] = map[string][]string{}
 		}
-		// only process liveries with configured base containers
-		// check if this base container is part of the configuration otherwise skip
-		if !config.Configuration.Ini.Section("defaultTypes").HasKey(cLivery.BaseContainer) {
+		Rules[icao][r.TypeCode] = r.Models
+	}
+	DefaultTypes = cfg.DefaultTypes
+	TypeVariations = cfg.TypeVariations
+	IcaoVariations = make(map[string][]string, len(cfg.IcaoVariations))
+	for _, group := range cfg.IcaoVariations {
+		IcaoVariations[group.Name] = group.Icaos
+	}
+	addBaseAircraftTypes(liveries, TypeVariations)
+	Dirty = true
+}
+
+// base containers without configured type variations use the ICAO type designator
+// from the [GENERAL] section of the installed base aircraft as only type variation
+func addBaseAircraftTypes(liveries []*livery.Livery, typeVariations map[string][]string) {
+	for _, l := range liveries {
+		if l.BaseAircraft == nil || l.BaseAircraft.IcaoTypeDesignator == "" {
 			continue
 		}
-		// get alternate icao variations from config if available
-		icaoList := findIcaoVariations(cLivery, IcaoVariations)
-		// add icao and all variations to rules
-		for _, icao := range icaoList {
-			// init map for new entries
-			if _, ok := Rules[icao]; !ok {
-				Rules[icao] = map[string][]string{}
-			}
-			for _, typeVariation := range TypeVariations[cLivery.BaseContainer] {
-				// add the cLivery to the rule
-				Rules[icao][typeVariation] = append(Rules[icao][typeVariation], cLivery.Title)
-				// this counts each cLivery for the same icao and type
-				Counter++
-			}
+		if _, found := typeVariations[l.BaseContainer]; !found {
+			typeVariations[l.BaseContainer] = []string{l.BaseAircraft.IcaoTypeDesignator}
 		}
 	}
-	Dirty = true
 }
 
 // check if the ICAO has alternative ICAOs which should use the same livery
-func findIcaoVariations(l *livery.Livery, icaoVariations map[string][]string) []string {
-	var icaoList []string
-	for k := range icaoVariations {
+func findIcaoVariations(l *livery.Livery, icaoVariations []config.IcaoGroup) []string {
+	for _, group := range icaoVariations {
 		// is this ICAO part of the variations?
-		for _, v := range icaoVariations[k] {
+		for _, v := range group.Icaos {
 			if v == l.Icao {
-				icaoList = []string{}
-				icaoList = append(icaoList, icaoVariations[k]...)
-				return icaoList
+				return append([]string{}, group.Icaos...)
 			}
 		}
 	}
@@ -164,87 +173,44 @@ func SortBaseKeys(m map[string][]string) []string {
 
 // GenerateXML generates a string with the XML representation of all matching rules.
 // Also returns the number of rules generated.
+//
+// Deprecated: use RuleSet.GenerateXML
 func GenerateXML() (strings.Builder, int) {
-	numberOfLines := 0
-
+	mu.Lock()
+	rs := current
+	mu.Unlock()
+	xml, numberOfLines := rs.GenerateXML()
 	var output strings.Builder
-	output.Grow(100_000)
-
-	// Header
-	output.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\r\n")
-	output.WriteString("<ModelMatchRuleSet>\r\n\r\n")
-
-	// default rules
-	fmt.Fprintf(&output, "<!-- DEFAULTS -->\r\n")
-	for _, icaoKey := range SortIcaoKeys(Rules) {
-		if icaoKey != "default" {
-			continue
-		}
-		if len(Rules[icaoKey]) == 0 {
-			break
-		}
-		for _, baseKey := range SortBaseKeys(DefaultTypes) { // only iterate over types with default livery
-			fmt.Fprintf(&output, "<!-- BASE: %s -->\r\n", baseKey)
-			for _, typeKey := range TypeVariations[baseKey] {
-				if len(Rules[icaoKey][typeKey]) == 0 {
-					continue
-				}
-				fmt.Fprintf(&output, "<ModelMatchRule TypeCode=\"%s\" ModelName=\"", typeKey)
-				for i, livery := range Rules[icaoKey][typeKey] {
-					if i != 0 {
-						fmt.Fprint(&output, "//")
-					}
-					fmt.Fprintf(&output, "%s", livery)
-				}
-				fmt.Fprintf(&output, "\" />\r\n")
-			}
-		}
-	}
-	fmt.Fprintf(&output, "\r\n")
-
-	// ICAO based rules
-	fmt.Fprintf(&output, "<!-- PER ICAO RULES -->\r\n")
-	for _, icaoKey := range SortIcaoKeys(Rules) {
-		if icaoKey == "default" || len(Rules[icaoKey]) == 0 {
-			continue
-		}
-		fmt.Fprintf(&output, "<!-- ICAO: %s -->\r\n", icaoKey)
-		for _, baseKey := range SortBaseKeys(DefaultTypes) { // only iterate over types with default livery
-			fmt.Fprintf(&output, "<!-- BASE: %s -->\r\n", baseKey)
-			for _, typeKey := range TypeVariations[baseKey] {
-				if len(Rules[icaoKey][typeKey]) == 0 {
-					continue
-				}
-				fmt.Fprintf(&output, "<ModelMatchRule CallsignPrefix=\"%s\" TypeCode=\"%s\" ModelName=\"", icaoKey, typeKey)
-				for i, livery := range Rules[icaoKey][typeKey] {
-					if i != 0 {
-						fmt.Fprint(&output, "//")
-					}
-					fmt.Fprintf(&output, "%s", livery)
-				}
-				fmt.Fprintf(&output, "\" />\r\n")
-				numberOfLines++
-			}
-		}
-		fmt.Fprintf(&output, "\r\n")
-	}
-
-	// Footer
-	output.WriteString("\r\n</ModelMatchRuleSet>\r\n")
+	output.WriteString(xml)
 	return output, numberOfLines
 }
 
+// SaveRulesToFile saves the rules of the last calculation to the output file
+//
+// Deprecated: use RuleSet.SaveToFile
 func SaveRulesToFile() error {
-	outPutFile := config.Configuration.Ini.Section("paths").Key("outputFile").String()
-	err := util.CreateBackup(outPutFile)
-	if err != nil {
+	mu.Lock()
+	rs := current
+	mu.Unlock()
+	if err := rs.SaveToFile(config.Configuration.Ini.Section("paths").Key("outputFile").String()); err != nil {
 		return err
 	}
-	var output, _ = GenerateXML()
-	err = util.SaveToFile(outPutFile, output)
+	Dirty = false
+	return nil
+}
+
+// SaveRulesToFileIfChanged saves the rules only if the generated XML differs from
+// the content of the output file. Returns true if the file has been written.
+//
+// Deprecated: use RuleSet.SaveToFileIfChanged
+func SaveRulesToFileIfChanged() (bool, error) {
+	mu.Lock()
+	rs := current
+	mu.Unlock()
+	saved, err := rs.SaveToFileIfChanged(config.Configuration.Ini.Section("paths").Key("outputFile").String())
 	if err != nil {
-		return err
+		return false, err
 	}
 	Dirty = false
-	return nil
+	return saved, nil
 }
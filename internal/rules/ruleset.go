@@ -0,0 +1,253 @@
+/*
+ * MatchMaker - create model matching files for VATSIM vPilot
+ *
+ *  MIT License
+ *
+ *  Copyright (c) 2021 Frank Kopp
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NON-INFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ *
+ */
+
+package rules
+
+import (
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/frankkopp/MatchMaker/internal/util"
+)
+
+// Rule maps an ICAO and type code to the liveries vPilot should use
+// <ModelMatchRule CallsignPrefix="DLH" TypeCode="A380" ModelName="Boeing 747-8i Lufthansa" />
+type Rule struct {
+	Icao          string   // CallsignPrefix - empty for the default rules of a type code
+	TypeCode      string   // ICAO type designator
+	BaseContainer string   // base container the type code is configured for - empty for imported rules
+	Models        []string // titles of the liveries
+}
+
+// Default returns true if the rule is used for all ICAOs without own rules
+func (r Rule) Default() bool {
+	return r.Icao == ""
+}
+
+// Stats are the numbers of a calculated rule set
+type Stats struct {
+	Mappings     int // liveries per ICAO and type code including the defaults
+	DefaultRules int // rules without ICAO
+	IcaoRules    int // rules with ICAO
+	Icaos        int // ICAOs with rules
+	Liveries     int // liveries used for rules
+	Imported     int // rules of imported VMR files by CallsignPrefix and TypeCode
+	Fallbacks    int // ICAO and type codes using the liveries of an [icaoFallbacks] ICAO
+	CrossBase    int // ICAO and type codes using the liveries of the ICAO on another base container
+}
+
+// RuleSet is the immutable result of a rules calculation
+type RuleSet struct {
+	bases  []string                       // base containers with default liveries in order
+	models map[string]map[string][]string // map[ICAO][TypeCode][]titles
+	rules  []Rule
+	stats  Stats
+
+	fallbacks map[string]int // ICAO -> type codes using an [icaoFallbacks] ICAO
+	crossBase map[string]int // ICAO -> type codes using a cross base fallback
+	xml       *xmlCache      // XML of the ICAO sections - nil if not cached
+}
+
+// xmlCache keeps the generated XML of the ICAO sections of a rule set so that
+// an updated rule set only generates the sections of changed ICAOs
+type xmlCache struct {
+	mu       sync.Mutex
+	sections map[string]string // ICAO -> XML of its section - "" for the defaults
+}
+
+func newXMLCache() *xmlCache {
+	return &xmlCache{sections: map[string]string{}}
+}
+
+// Rules returns a copy of all rules ordered by ICAO (defaults first), base container and type code
+func (rs *RuleSet) Rules() []Rule {
+	rules := make([]Rule, len(rs.rules))
+	for i, r := range rs.rules {
+		r.Models = append([]string(nil), r.Models...)
+		rules[i] = r
+	}
+	return rules
+}
+
+// Stats returns the numbers of the rule set
+func (rs *RuleSet) Stats() Stats {
+	return rs.stats
+}
+
+// Models returns the titles of the liveries for the ICAO and type code - use
+// an empty ICAO for the default liveries. Returns nil if there is no rule.
+func (rs *RuleSet) Models(icao, typeCode string) []string {
+	return append([]string(nil), rs.models[icao][typeCode]...)
+}
+
+// VMR returns the rules as typed VMR rule set with the comment sections for
+// the DEFAULTS, each ICAO and each BASE container.
+func (rs *RuleSet) VMR() *ModelMatchRuleSet {
+	set := &ModelMatchRuleSet{}
+	for _, icao := range rs.icaos() {
+		rs.addSection(set, icao)
+	}
+	return set
+}
+
+// icaos returns the ICAOs with rules in the order of the rules - "" for the
+// defaults is always the first
+func (rs *RuleSet) icaos() []string {
+	icaos := []string{""}
+	for _, r := range rs.rules {
+		if r.Icao != icaos[len(icaos)-1] {
+			icaos = append(icaos, r.Icao)
+		}
+	}
+	return icaos
+}
+
+// addSection adds the rules of the ICAO with the comments of its section - the
+// section of the defaults ("") is followed by the PER ICAO RULES comment
+func (rs *RuleSet) addSection(set *ModelMatchRuleSet, icao string) {
+	// the rules are ordered by ICAO and base container - next is the rule to add next
+	next := sort.Search(len(rs.rules), func(i int) bool { return rs.rules[i].Icao >= icao })
+	addRules := func(icao, base string) {
+		for ; next < len(rs.rules) && rs.rules[next].Icao == icao && rs.rules[next].BaseContainer == base; next++ {
+			r := rs.rules[next]
+			set.AddRule(ModelMatchRule{CallsignPrefix: r.Icao, TypeCode: r.TypeCode, ModelName: strings.Join(r.Models, "//")})
+		}
+	}
+
+	// rules of imported VMR files have no base container and come last
+	addImported := func(icao string) {
+		if next < len(rs.rules) && rs.rules[next].Icao == icao && rs.rules[next].BaseContainer == "" {
+			set.AddComment("IMPORTED")
+			addRules(icao, "")
+		}
+	}
+
+	// default rules
+	if icao == "" {
+		set.AddComment("DEFAULTS")
+		if next < len(rs.rules) && rs.rules[next].Default() {
+			for _, base := range rs.bases {
+				set.AddComment("BASE: " + base)
+				addRules("", base)
+			}
+			addImported("")
+		}
+		set.AddComment("")
+		set.AddComment("PER ICAO RULES")
+		return
+	}
+
+	// ICAO based rules
+	set.AddComment("ICAO: " + icao)
+	for _, base := range rs.bases {
+		set.AddComment("BASE: " + base)
+		addRules(icao, base)
+	}
+	addImported(icao)
+	set.AddComment("")
+}
+
+// sectionXML returns the XML of the section of the ICAO - from the cache if
+// it has been generated before
+func (rs *RuleSet) sectionXML(icao string) string {
+	if rs.xml != nil {
+		rs.xml.mu.Lock()
+		defer rs.xml.mu.Unlock()
+		if section, ok := rs.xml.sections[icao]; ok {
+			return section
+		}
+	}
+	set := &ModelMatchRuleSet{}
+	rs.addSection(set, icao)
+	var section strings.Builder
+	set.writeRules(&section)
+	if rs.xml != nil {
+		rs.xml.sections[icao] = section.String()
+	}
+	return section.String()
+}
+
+// GenerateXML generates a string with the XML representation of all matching rules.
+// Also returns the number of ICAO rules generated.
+func (rs *RuleSet) GenerateXML() (string, int) {
+	var output strings.Builder
+	output.Grow(100_000)
+	output.WriteString(xmlHeader)
+	for _, icao := range rs.icaos() {
+		output.WriteString(rs.sectionXML(icao))
+	}
+	output.WriteString(xmlFooter)
+	return output.String(), rs.stats.IcaoRules
+}
+
+// ValidXML generates the XML of all matching rules and checks that it is a
+// valid rule set which can be saved
+func (rs *RuleSet) ValidXML() (string, error) {
+	output, _ := rs.GenerateXML()
+	if err := validateXML(output, len(rs.rules)); err != nil {
+		return "", err
+	}
+	return output, nil
+}
+
+// SaveToFile validates the XML of the rules and writes it to the file. A backup
+// of an existing file is created.
+func (rs *RuleSet) SaveToFile(file string) error {
+	output, err := rs.ValidXML()
+	if err != nil {
+		return err
+	}
+	return saveXML(file, output)
+}
+
+// saves the XML to the file and creates a backup of an existing file
+func saveXML(file, output string) error {
+	if err := util.CreateBackup(file); err != nil {
+		return err
+	}
+	var builder strings.Builder
+	builder.WriteString(output)
+	return util.SaveToFile(file, builder)
+}
+
+// SaveToFileIfChanged saves the rules only if the generated XML differs from
+// the content of the file. Returns true if the file has been written.
+func (rs *RuleSet) SaveToFileIfChanged(file string) (bool, error) {
+	output, err := rs.ValidXML()
+	if err != nil {
+		return false, err
+	}
+	if current, err := ioutil.ReadFile(file); err == nil && string(current) == output {
+		return false, nil
+	}
+	if err := saveXML(file, output); err != nil {
+		return false, err
+	}
+	return true, nil
+}
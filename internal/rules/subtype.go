@@ -0,0 +1,70 @@
+/*
+ * MatchMaker - create model matching files for VATSIM vPilot
+ *
+ *  MIT License
+ *
+ *  Copyright (c) 2021 Frank Kopp
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NON-INFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ *
+ */
+
+package rules
+
+import (
+	"strings"
+
+	"github.com/frankkopp/MatchMaker/internal/livery"
+)
+
+// liveryTypeCodes returns the type codes of the first pattern of
+// [subtypePatterns] which matches the title or the ui_variation of the livery.
+// Only the type codes of the type variations of its base container are used.
+// Returns all type variations if no pattern matches or none of its type codes
+// is a type variation of the base container.
+func (e *Engine) liveryTypeCodes(l *livery.Livery, typeVariations []string) []string {
+	for _, p := range e.config.SubtypePatterns {
+		if !matchSubtype(p.Pattern, l.Title) && !matchSubtype(p.Pattern, l.UiVariation) {
+			continue
+		}
+		var typeCodes []string
+		for _, typeCode := range typeVariations {
+			if indexOf(p.TypeCodes, typeCode) >= 0 {
+				typeCodes = append(typeCodes, typeCode)
+			}
+		}
+		if len(typeCodes) > 0 {
+			return typeCodes
+		}
+		return typeVariations
+	}
+	return typeVariations
+}
+
+// matchSubtype matches the text against a subtype pattern - a pattern without
+// * or ? matches if the text contains it. * matches any text including "/".
+func matchSubtype(pattern, text string) bool {
+	if text == "" {
+		return false
+	}
+	if !strings.ContainsAny(pattern, "*?") {
+		pattern = "*" + pattern + "*"
+	}
+	return matchPattern(pattern, text)
+}
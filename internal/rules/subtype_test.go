@@ -0,0 +1,87 @@
+/*
+ * MatchMaker - create model matching files for VATSIM vPilot
+ *
+ *  MIT License
+ *
+ *  Copyright (c) 2021 Frank Kopp
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NON-INFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ *
+ */
+
+package rules
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/frankkopp/MatchMaker/internal/config"
+	"github.com/frankkopp/MatchMaker/internal/livery"
+)
+
+func TestEngineSubtypePatterns(t *testing.T) {
+	const ini = `
+[defaultTypes]
+Asobo_A320_NEO = Airbus A320 Neo Asobo
+[typeVariations]
+Asobo_A320_NEO = A20N,A320,A321,A21N,A319
+[subtypePatterns]
+A321neo = A21N
+A321    = A321,A21N
+A319    = A319
+*B77W*  = B77W
+`
+	liveries := []*livery.Livery{
+		{Title: "A320 Lufthansa", Icao: "DLH", BaseContainer: "Asobo_A320_NEO"},
+		{Title: "Airbus a321NEO Lufthansa", Icao: "DLH", BaseContainer: "Asobo_A320_NEO"},
+		{Title: "Lufthansa D-AIDA", Icao: "DLH", BaseContainer: "Asobo_A320_NEO", UiVariation: "A321 Lufthansa"},
+		{Title: "A319 Eurowings", Icao: "EWG", BaseContainer: "Asobo_A320_NEO"},
+		{Title: "B77W Emirates on A320", Icao: "UAE", BaseContainer: "Asobo_A320_NEO"},
+		{Title: "Airbus A321 Lufthansa / Star Alliance", Icao: "STA", BaseContainer: "Asobo_A320_NEO"},
+		{Title: "Star Alliance / Airbus A319", Icao: "STA", BaseContainer: "Asobo_A320_NEO"},
+	}
+	if err := config.Configuration.LoadFromString(ini); err != nil {
+		t.Fatal(err)
+	}
+	rs := NewEngine(NewConfig(&config.Configuration)).Calculate(liveries)
+	tests := []struct {
+		icao, typeCode string
+		want           []string
+	}{
+		{"DLH", "A320", []string{"A320 Lufthansa"}},
+		{"DLH", "A20N", []string{"A320 Lufthansa"}},
+		// liveries without matching pattern keep all type variations
+		{"DLH", "A321", []string{"A320 Lufthansa", "Lufthansa D-AIDA"}},
+		{"DLH", "A21N", []string{"A320 Lufthansa", "Airbus a321NEO Lufthansa", "Lufthansa D-AIDA"}},
+		{"EWG", "A319", []string{"A319 Eurowings"}},
+		{"EWG", "A320", nil},
+		// a pattern without type code of the base container uses all type variations
+		{"UAE", "A320", []string{"B77W Emirates on A320"}},
+		{"UAE", "A319", []string{"B77W Emirates on A320"}},
+		// titles with a "/" are matched as well
+		{"STA", "A321", []string{"Airbus A321 Lufthansa / Star Alliance"}},
+		{"STA", "A319", []string{"Star Alliance / Airbus A319"}},
+		{"STA", "A320", nil},
+	}
+	for _, tt := range tests {
+		if got := rs.Models(tt.icao, tt.typeCode); len(got) != len(tt.want) || len(tt.want) > 0 && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Models(%s, %s) = %v, want %v", tt.icao, tt.typeCode, got, tt.want)
+		}
+	}
+}
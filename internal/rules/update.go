@@ -0,0 +1,143 @@
+/*
+ * MatchMaker - create model matching files for VATSIM vPilot
+ *
+ *  MIT License
+ *
+ *  Copyright (c) 2021 Frank Kopp
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NON-INFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ *
+ */
+
+package rules
+
+import (
+	"github.com/frankkopp/MatchMaker/internal/livery"
+)
+
+// Update recalculates a rule set after some liveries have been changed, e.g.
+// disabled or given a custom ICAO. Only the rules of the given ICAOs ("" for
+// the default liveries) and of the ICAOs sharing their liveries through
+// [icaoVariations] or [icaoFallbacks] are calculated again - for a changed ICAO
+// both the ICAO before and after the change have to be given. The other rules
+// and their XML are taken from the rule set.
+// The rule set has to be calculated by Calculate with the same configuration
+// except for the default liveries and the custom data of the changed liveries.
+// If the base containers have changed all rules are calculated again.
+func (e *Engine) Update(rs *RuleSet, liveries []*livery.Livery, icaos ...string) *RuleSet {
+	typeVariations, bases := e.prepare(liveries)
+	if !equalStrings(bases, rs.bases) {
+		return e.Calculate(liveries)
+	}
+	affected := e.affectedIcaos(icaos)
+
+	// the affected ICAOs need the own liveries of their fallbacks
+	needed := map[string]bool{}
+	for icao := range affected {
+		e.collectFallbacks(icao, needed)
+	}
+	candidates, count := e.collect(liveries, typeVariations, bases, func(icao string) bool { return needed[icao] })
+	calculated := e.buildModels(candidates, typeVariations)
+	calculatedFallbacks := e.addFallbacks(calculated)
+	calculatedCrossBase := e.addCrossBase(candidates, calculated, bases, typeVariations)
+
+	// take the rules of the not affected ICAOs from the rule set
+	models := make(map[string]map[string][]string, len(rs.models))
+	fallbacks := map[string]int{}
+	crossBase := map[string]int{}
+	for icao, types := range rs.models {
+		if !affected[icao] {
+			models[icao] = types
+			fallbacks[icao] = rs.fallbacks[icao]
+			crossBase[icao] = rs.crossBase[icao]
+		}
+	}
+	for icao := range affected {
+		if len(calculated[icao]) > 0 || icao == "" {
+			models[icao] = calculated[icao]
+			fallbacks[icao] = calculatedFallbacks[icao]
+			crossBase[icao] = calculatedCrossBase[icao]
+		}
+	}
+
+	updated := newRuleSet(models, bases, typeVariations, count, fallbacks, crossBase)
+	if rs.xml != nil {
+		rs.xml.mu.Lock()
+		for icao, section := range rs.xml.sections {
+			if !affected[icao] {
+				updated.xml.sections[icao] = section
+			}
+		}
+		rs.xml.mu.Unlock()
+	}
+	return updated
+}
+
+// affectedIcaos returns the ICAOs whose rules change if the liveries of the
+// ICAOs change. These are the ICAOs of their [icaoVariations] groups and all
+// ICAOs which use them as fallback.
+func (e *Engine) affectedIcaos(icaos []string) map[string]bool {
+	affected := map[string]bool{}
+	for _, icao := range icaos {
+		for _, v := range findIcaoVariations(&livery.Livery{Icao: icao}, e.config.IcaoVariations) {
+			affected[v] = true
+		}
+	}
+	// an ICAO is affected if one of its fallbacks is affected
+	for changed := true; changed; {
+		changed = false
+		for icao, list := range e.config.IcaoFallbacks {
+			if affected[icao] {
+				continue
+			}
+			for _, fallback := range list {
+				if affected[fallback] {
+					affected[icao] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+	return affected
+}
+
+// collectFallbacks adds the ICAO and all its fallbacks
+func (e *Engine) collectFallbacks(icao string, icaos map[string]bool) {
+	if icaos[icao] {
+		return
+	}
+	icaos[icao] = true
+	for _, fallback := range e.config.IcaoFallbacks[icao] {
+		e.collectFallbacks(fallback, icaos)
+	}
+}
+
+// equalStrings returns true if both lists have the same strings in the same order
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,106 @@
+/*
+ * MatchMaker - create model matching files for VATSIM vPilot
+ *
+ *  MIT License
+ *
+ *  Copyright (c) 2021 Frank Kopp
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NON-INFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ *
+ */
+
+package rules
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/frankkopp/MatchMaker/internal/config"
+	"github.com/frankkopp/MatchMaker/internal/livery"
+)
+
+func TestEngineUpdate(t *testing.T) {
+	const ini = `
+[rules]
+crossBaseFallback = true
+[defaultTypes]
+Asobo_A320_NEO = Airbus A320 Neo Asobo
+Asobo_B787_10  = Boeing 787-10 Asobo
+[typeVariations]
+Asobo_A320_NEO = A20N,A320
+Asobo_B787_10  = B789
+[icaoVariations]
+Lufthansa = DLH,LHA
+[icaoFallbacks]
+CLH = DLH
+`
+	liveries := func() []*livery.Livery {
+		return []*livery.Livery{
+			{Title: "A320 Lufthansa", Icao: "DLH", BaseContainer: "Asobo_A320_NEO"},
+			{Title: "B787 Lufthansa", Icao: "DLH", BaseContainer: "Asobo_B787_10"},
+			{Title: "A320 CityLine", Icao: "CLH", BaseContainer: "Asobo_A320_NEO"},
+			{Title: "A320 Condor", Icao: "CFG", BaseContainer: "Asobo_A320_NEO"},
+			{Title: "B787 Condor", Icao: "CFG", BaseContainer: "Asobo_B787_10"},
+		}
+	}
+	tests := []struct {
+		name   string
+		change func(all []*livery.Livery)
+		icaos  []string
+	}{
+		{"disable", func(all []*livery.Livery) { all[1].SetReason(livery.ReasonDisabledByCustom, true) }, []string{"DLH"}},
+		{"custom ICAO", func(all []*livery.Livery) { all[3].SetIcao("EWG") }, []string{"CFG", "EWG"}},
+		{"last livery of an ICAO", func(all []*livery.Livery) { all[2].SetReason(livery.ReasonDisabledByCustom, true) }, []string{"CLH"}},
+		{"default livery", func(all []*livery.Livery) {
+			config.Configuration.AddLiveryToDefault("Asobo_A320_NEO", "A320 Condor")
+		}, []string{""}},
+		{"new base container", func(all []*livery.Livery) {
+			config.Configuration.AddLiveryToDefault("Asobo_CJ4", "Cessna CJ4 Citation Asobo")
+		}, []string{""}},
+	}
+	for _, tt := range tests {
+		if err := config.Configuration.LoadFromString(ini); err != nil {
+			t.Fatal(err)
+		}
+		all := liveries()
+		rs := NewEngine(NewConfig(&config.Configuration)).Calculate(all)
+		before, _ := rs.GenerateXML() // fills the XML cache
+
+		tt.change(all)
+		engine := NewEngine(NewConfig(&config.Configuration))
+		updated := engine.Update(rs, all, tt.icaos...)
+		want := engine.Calculate(all)
+
+		if !reflect.DeepEqual(updated.Rules(), want.Rules()) {
+			t.Errorf("%s: Update() rules = %+v, want %+v", tt.name, updated.Rules(), want.Rules())
+		}
+		if updated.Stats() != want.Stats() {
+			t.Errorf("%s: Update() stats = %+v, want %+v", tt.name, updated.Stats(), want.Stats())
+		}
+		got, n := updated.GenerateXML()
+		xml, wantN := want.GenerateXML()
+		if got != xml || n != wantN || got != updated.VMR().XML() {
+			t.Errorf("%s: Update() XML =\n%s\nwant\n%s", tt.name, got, xml)
+		}
+		// the rule set itself is not changed
+		if again, _ := rs.GenerateXML(); again != before {
+			t.Errorf("%s: Update() changed the rule set", tt.name)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+/*
+ * MatchMaker - create model matching files for VATSIM vPilot
+ *
+ *  MIT License
+ *
+ *  Copyright (c) 2021 Frank Kopp
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NON-INFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ *
+ */
+
+package rules
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// ModelMatchRuleSet is the root element of a vPilot model matching rules file (VMR)
+type ModelMatchRuleSet struct {
+	XMLName xml.Name         `xml:"ModelMatchRuleSet"`
+	Rules   []ModelMatchRule `xml:"ModelMatchRule"`
+
+	// comments[i] are the comment lines written before Rules[i] - an empty
+	// comment is written as an empty line. comments[len(Rules)] are written
+	// after the last rule.
+	comments map[int][]string
+}
+
+// ModelMatchRule maps a callsign prefix and type code to the models vPilot
+// should use - ModelName has the titles of the liveries separated by "//"
+type ModelMatchRule struct {
+	CallsignPrefix string `xml:"CallsignPrefix,attr,omitempty"`
+	TypeCode       string `xml:"TypeCode,attr"`
+	ModelName      string `xml:"ModelName,attr"`
+}
+
+// AddComment adds a comment before the next rule - an empty text adds an empty line
+func (s *ModelMatchRuleSet) AddComment(text string) {
+	if s.comments == nil {
+		s.comments = map[int][]string{}
+	}
+	s.comments[len(s.Rules)] = append(s.comments[len(s.Rules)], text)
+}
+
+// AddRule adds a rule after all rules and comments added before
+func (s *ModelMatchRuleSet) AddRule(rule ModelMatchRule) {
+	s.Rules = append(s.Rules, rule)
+}
+
+// XML serializes the rule set in the layout vPilot uses with one rule per line.
+// Attribute values are escaped - comments must not contain "--" and are changed
+// if necessary.
+func (s *ModelMatchRuleSet) XML() string {
+	var output strings.Builder
+	output.Grow(100_000)
+	output.WriteString(xmlHeader)
+	s.writeRules(&output)
+	output.WriteString(xmlFooter)
+	return output.String()
+}
+
+const (
+	xmlHeader = "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\r\n<ModelMatchRuleSet>\r\n\r\n"
+	xmlFooter = "\r\n</ModelMatchRuleSet>\r\n"
+)
+
+// writes the rules with their comments without header and footer
+func (s *ModelMatchRuleSet) writeRules(output *strings.Builder) {
+	for i := 0; i <= len(s.Rules); i++ {
+		for _, c := range s.comments[i] {
+			writeComment(output, c)
+		}
+		if i == len(s.Rules) {
+			break
+		}
+		r := s.Rules[i]
+		output.WriteString("<ModelMatchRule")
+		if r.CallsignPrefix != "" {
+			writeAttr(output, "CallsignPrefix", r.CallsignPrefix)
+		}
+		writeAttr(output, "TypeCode", r.TypeCode)
+		writeAttr(output, "ModelName", r.ModelName)
+		output.WriteString(" />\r\n")
+	}
+}
+
+// writes the attribute with the escaped value
+func writeAttr(output *strings.Builder, name, value string) {
+	var escaped bytes.Buffer
+	_ = xml.EscapeText(&escaped, []byte(value)) // writing to a bytes.Buffer can't fail
+	fmt.Fprintf(output, " %s=\"%s\"", name, escaped.String())
+}
+
+// writes the comment as a line - XML does not allow "--" within comments
+func writeComment(output *strings.Builder, text string) {
+	if text == "" {
+		output.WriteString("\r\n")
+		return
+	}
+	for strings.Contains(text, "--") {
+		text = strings.ReplaceAll(text, "--", "- -")
+	}
+	fmt.Fprintf(output, "<!-- %s -->\r\n", text)
+}
+
+// validateXML checks that the XML is a well formed rule set with the given
+// number of rules which all have a type code and a model name
+func validateXML(data string, rules int) error {
+	var set ModelMatchRuleSet
+	if err := xml.Unmarshal([]byte(data), &set); err != nil {
+		return fmt.Errorf("generated rules are not valid XML: %w", err)
+	}
+	if len(set.Rules) != rules {
+		return fmt.Errorf("generated XML has %d rules, want %d", len(set.Rules), rules)
+	}
+	for i, r := range set.Rules {
+		if r.TypeCode == "" || r.ModelName == "" {
+			return fmt.Errorf("rule %d (%s) has no TypeCode or ModelName", i+1, r.CallsignPrefix)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+/*
+ * MatchMaker - create model matching files for VATSIM vPilot
+ *
+ *  MIT License
+ *
+ *  Copyright (c) 2021 Frank Kopp
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NON-INFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ *
+ */
+
+package rules
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/frankkopp/MatchMaker/internal/config"
+	"github.com/frankkopp/MatchMaker/internal/livery"
+)
+
+func TestGenerateXMLEscaping(t *testing.T) {
+	if err := config.Configuration.LoadFromString(testIni); err != nil {
+		t.Fatal(err)
+	}
+	title := `Airbus A320 "Neo" <Lufthansa> & Friends`
+	liveries := []*livery.Livery{{Title: title, Icao: "DLH", BaseContainer: "Asobo_A320_NEO"}}
+	rs := NewEngine(NewConfig(&config.Configuration)).Calculate(liveries)
+	output, err := rs.ValidXML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(output, `ModelName="Airbus A320 &#34;Neo&#34; &lt;Lufthansa&gt; &amp; Friends"`) {
+		t.Errorf("title not escaped:\n%s", output)
+	}
+	var set ModelMatchRuleSet
+	if err := xml.Unmarshal([]byte(output), &set); err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, r := range set.Rules {
+		if r.CallsignPrefix == "DLH" && r.ModelName == title {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("title %q not found in %+v", title, set.Rules)
+	}
+}
+
+func TestModelMatchRuleSetXML(t *testing.T) {
+	set := &ModelMatchRuleSet{}
+	set.AddComment("BASE: Broken--Base-")
+	set.AddRule(ModelMatchRule{TypeCode: "A320", ModelName: "Default"})
+	set.AddComment("")
+	set.AddRule(ModelMatchRule{CallsignPrefix: "DLH", TypeCode: "A320", ModelName: "A & B"})
+	want := "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\r\n<ModelMatchRuleSet>\r\n\r\n" +
+		"<!-- BASE: Broken- -Base- -->\r\n" +
+		"<ModelMatchRule TypeCode=\"A320\" ModelName=\"Default\" />\r\n" +
+		"\r\n" +
+		"<ModelMatchRule CallsignPrefix=\"DLH\" TypeCode=\"A320\" ModelName=\"A &amp; B\" />\r\n" +
+		"\r\n</ModelMatchRuleSet>\r\n"
+	if got := set.XML(); got != want {
+		t.Errorf("XML() = %q, want %q", got, want)
+	}
+	if err := validateXML(set.XML(), 2); err != nil {
+		t.Errorf("validateXML() = %v", err)
+	}
+}
+
+func Test_validateXML(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  string
+		rules int
+	}{
+		{"unescaped title", `<ModelMatchRuleSet><ModelMatchRule TypeCode="A320" ModelName="A & B" /></ModelMatchRuleSet>`, 1},
+		{"unclosed", `<ModelMatchRuleSet><ModelMatchRule TypeCode="A320" ModelName="A" />`, 1},
+		{"missing rule", `<ModelMatchRuleSet><ModelMatchRule TypeCode="A320" ModelName="A" /></ModelMatchRuleSet>`, 2},
+		{"no model", `<ModelMatchRuleSet><ModelMatchRule TypeCode="A320" /></ModelMatchRuleSet>`, 1},
+	}
+	for _, tt := range tests {
+		if err := validateXML(tt.data, tt.rules); err == nil {
+			t.Errorf("%s: validateXML() = nil, want error", tt.name)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+/*
+ * MatchMaker - create model matching files for VATSIM vPilot
+ *
+ *  MIT License
+ *
+ *  Copyright (c) 2021 Frank Kopp
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NON-INFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ *
+ */
+
+package rules
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/frankkopp/MatchMaker/internal/livery"
+)
+
+// weight returns how often the title of the livery is repeated in its rules.
+// The weight of the custom data is used before the first matching pattern of
+// [titleWeights] and the weight of the package in [packageWeights]. Default 1.
+func (e *Engine) weight(l *livery.Livery) int {
+	if weight, ok := e.config.CustomWeights[l.AircraftCfgFile]; ok {
+		return weight
+	}
+	defaultWeight := 1
+	if l.Package != nil {
+		if weight, ok := e.config.PackageWeights[l.Package.Name]; ok {
+			defaultWeight = weight
+		}
+	}
+	return e.titleWeight(l.Title, defaultWeight)
+}
+
+// titleWeight returns the weight of the first pattern of [titleWeights] which
+// matches the title or the default weight. Patterns are case-insensitive.
+func (e *Engine) titleWeight(title string, defaultWeight int) int {
+	for _, w := range e.config.TitleWeights {
+		if matchPattern(w.Pattern, title) {
+			return w.Weight
+		}
+	}
+	return defaultWeight
+}
+
+// patterns caches the compiled regular expressions of the patterns
+var patterns sync.Map
+
+// matchPattern matches the complete text against a case-insensitive pattern.
+// * matches any text including "/" and ? any single character.
+func matchPattern(pattern, text string) bool {
+	re, ok := patterns.Load(pattern)
+	if !ok {
+		re, _ = patterns.LoadOrStore(pattern, compilePattern(pattern))
+	}
+	return re.(*regexp.Regexp).MatchString(text)
+}
+
+// compilePattern translates the pattern into an anchored case-insensitive regular expression
+func compilePattern(pattern string) *regexp.Regexp {
+	var expr strings.Builder
+	expr.WriteString("(?is)^")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			expr.WriteString(".*")
+		case '?':
+			expr.WriteString(".")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	expr.WriteString("$")
+	return regexp.MustCompile(expr.String())
+}
+
+// selectTitles returns the titles of the candidates for a rule in their order.
+// Each title is repeated by its weight and candidates with weight 0 are
+// dropped. With maxTitlesPerRule only the different titles with the highest
+// weights are used - titles with the same weight in alphabetical order. A
+// title of several candidates counts once with its highest weight.
+func (e *Engine) selectTitles(list []candidate) []string {
+	var pool []candidate
+	weights := map[string]int{} // title -> highest weight
+	var titles []string         // different titles
+	for _, c := range list {
+		if c.weight == 0 {
+			continue
+		}
+		pool = append(pool, c)
+		if _, ok := weights[c.title]; !ok {
+			titles = append(titles, c.title)
+		}
+		if c.weight > weights[c.title] {
+			weights[c.title] = c.weight
+		}
+	}
+	if max := e.config.MaxTitlesPerRule; max > 0 && len(titles) > max {
+		sort.Slice(titles, func(i, j int) bool {
+			if weights[titles[i]] != weights[titles[j]] {
+				return weights[titles[i]] > weights[titles[j]]
+			}
+			return titles[i] < titles[j]
+		})
+		selected := make(map[string]bool, max)
+		for _, title := range titles[:max] {
+			selected[title] = true
+		}
+		var capped []candidate
+		for _, c := range pool {
+			if selected[c.title] {
+				capped = append(capped, c)
+			}
+		}
+		pool = capped
+	}
+	titles = nil
+	for _, c := range pool {
+		for n := 0; n < c.weight; n++ {
+			titles = append(titles, c.title)
+		}
+	}
+	return titles
+}
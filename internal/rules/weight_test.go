@@ -0,0 +1,149 @@
+/*
+ * MatchMaker - create model matching files for VATSIM vPilot
+ *
+ *  MIT License
+ *
+ *  Copyright (c) 2021 Frank Kopp
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NON-INFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ *
+ */
+
+package rules
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/frankkopp/MatchMaker/internal/config"
+	"github.com/frankkopp/MatchMaker/internal/livery"
+)
+
+func TestEngineWeights(t *testing.T) {
+	const ini = `
+[defaultTypes]
+Asobo_A320_NEO = Airbus A320 Neo Asobo
+[typeVariations]
+Asobo_A320_NEO = A320
+[titleWeights]
+*retro* = 0
+*Lufthansa*Star Alliance* = 2
+Airbus A320 Neo Asobo = 2
+[packageWeights]
+dlh-pack = 3
+[customData]
+Do not delete this line due to a bug in the ini library,false,,
+C:\Community\dlh-pack\c\aircraft.cfg,true,DLH,,1
+C:\Community\dlh-pack\d\aircraft.cfg,true,DLH,
+`
+	pack := &livery.Package{Name: "dlh-pack"}
+	liveries := []*livery.Livery{
+		{Title: "A320 Lufthansa", Icao: "DLH", BaseContainer: "Asobo_A320_NEO"},
+		{Title: "A320 Lufthansa Retro", Icao: "DLH", BaseContainer: "Asobo_A320_NEO", Package: pack},
+		{Title: "A320 Lufthansa Pack", Icao: "DLH", BaseContainer: "Asobo_A320_NEO", Package: pack},
+		{Title: "A320 Lufthansa Custom", Icao: "DLH", BaseContainer: "Asobo_A320_NEO", Package: pack, AircraftCfgFile: `C:\Community\dlh-pack\c\aircraft.cfg`},
+		{Title: "A320 Lufthansa Pack 2", Icao: "DLH", BaseContainer: "Asobo_A320_NEO", Package: pack, AircraftCfgFile: `C:\Community\dlh-pack\d\aircraft.cfg`},
+		{Title: "A320 Retro Only", Icao: "RTO", BaseContainer: "Asobo_A320_NEO"},
+		{Title: "Lufthansa Retro A320 / D-AIDA", Icao: "RTO", BaseContainer: "Asobo_A320_NEO"},
+		{Title: "A320 Lufthansa / Star Alliance", Icao: "STA", BaseContainer: "Asobo_A320_NEO"},
+	}
+	tests := []struct {
+		name string
+		max  string
+		dlh  []string
+	}{
+		{"weights", "", []string{
+			"A320 Lufthansa",
+			"A320 Lufthansa Pack", "A320 Lufthansa Pack", "A320 Lufthansa Pack",
+			"A320 Lufthansa Custom",
+			"A320 Lufthansa Pack 2", "A320 Lufthansa Pack 2", "A320 Lufthansa Pack 2",
+		}},
+		// the highest weights and then the titles in alphabetical order - in the order of the liveries
+		{"capped", "[rules]\nmaxTitlesPerRule = 2\n", []string{
+			"A320 Lufthansa Pack", "A320 Lufthansa Pack", "A320 Lufthansa Pack",
+			"A320 Lufthansa Pack 2", "A320 Lufthansa Pack 2", "A320 Lufthansa Pack 2",
+		}},
+		{"capped by title", "[rules]\nmaxTitlesPerRule = 3\n", []string{
+			"A320 Lufthansa",
+			"A320 Lufthansa Pack", "A320 Lufthansa Pack", "A320 Lufthansa Pack",
+			"A320 Lufthansa Pack 2", "A320 Lufthansa Pack 2", "A320 Lufthansa Pack 2",
+		}},
+	}
+	for _, tt := range tests {
+		if err := config.Configuration.LoadFromString(tt.max + ini); err != nil {
+			t.Fatal(err)
+		}
+		rs := NewEngine(NewConfig(&config.Configuration)).Calculate(liveries)
+		if got := rs.Models("DLH", "A320"); !reflect.DeepEqual(got, tt.dlh) {
+			t.Errorf("%s: Models(DLH, A320) = %v, want %v", tt.name, got, tt.dlh)
+		}
+		if got := rs.Models("", "A320"); !reflect.DeepEqual(got, []string{"Airbus A320 Neo Asobo", "Airbus A320 Neo Asobo"}) {
+			t.Errorf("%s: Models(\"\", A320) = %v", tt.name, got)
+		}
+		// a rule without titles is dropped - * matches a "/" in the title as well
+		if got := rs.Models("RTO", "A320"); len(got) != 0 {
+			t.Errorf("%s: Models(RTO, A320) = %v, want no rule", tt.name, got)
+		}
+		if got := rs.Models("STA", "A320"); !reflect.DeepEqual(got, []string{"A320 Lufthansa / Star Alliance", "A320 Lufthansa / Star Alliance"}) {
+			t.Errorf("%s: Models(STA, A320) = %v", tt.name, got)
+		}
+	}
+
+	// the weight is kept in the custom data when the entry is changed
+	custom := config.Configuration.Custom
+	custom.AddOrChangeEntry(`C:\Community\dlh-pack\c\aircraft.cfg`, false, "DLH", "CLH")
+	custom.SetWeight(`C:\Community\dlh-pack\e\aircraft.cfg`, 0, "DLH")
+	body := custom.GetDataBody()
+	for _, line := range []string{
+		"C:\\Community\\dlh-pack\\c\\aircraft.cfg,false,DLH,CLH,1\r\n",
+		"C:\\Community\\dlh-pack\\d\\aircraft.cfg,true,DLH,\r\n",
+		"C:\\Community\\dlh-pack\\e\\aircraft.cfg,true,DLH,,0\r\n",
+	} {
+		if !strings.Contains(body, line) {
+			t.Errorf("GetDataBody() has no %q:\n%s", line, body)
+		}
+	}
+}
+
+func TestEngineMaxTitlesDuplicates(t *testing.T) {
+	const ini = `
+[defaultTypes]
+Asobo_A320_NEO = Airbus A320 Neo Asobo
+[typeVariations]
+Asobo_A320_NEO = A320
+[rules]
+maxTitlesPerRule = 2
+`
+	if err := config.Configuration.LoadFromString(ini); err != nil {
+		t.Fatal(err)
+	}
+	liveries := []*livery.Livery{
+		{Title: "A320 Condor", Icao: "CFG", BaseContainer: "Asobo_A320_NEO"},
+		{Title: "A320 Condor", Icao: "CFG", BaseContainer: "Asobo_A320_NEO"},
+		{Title: "A320 Condor B", Icao: "CFG", BaseContainer: "Asobo_A320_NEO"},
+		{Title: "A320 Condor C", Icao: "CFG", BaseContainer: "Asobo_A320_NEO"},
+	}
+	// a title of several liveries counts once for maxTitlesPerRule
+	rs := NewEngine(NewConfig(&config.Configuration)).Calculate(liveries)
+	want := []string{"A320 Condor", "A320 Condor", "A320 Condor B"}
+	if got := rs.Models("CFG", "A320"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Models(CFG, A320) = %v, want %v", got, want)
+	}
+}
@@ -28,7 +28,12 @@
 package ui
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
+
 	"github.com/frankkopp/MatchMaker/internal/config"
+	"github.com/frankkopp/MatchMaker/internal/livery"
 	"github.com/lxn/walk"
 	. "github.com/lxn/walk/declarative"
 )
@@ -47,7 +52,9 @@ func EditDialog(owner walk.Form, indexes []int) (int, error) {
 	var (
 		processCheck *walk.CheckBox
 		customIcao   *walk.LineEdit
+		weightEdit   *walk.LineEdit
 	)
+	weight := weightText(item.AircraftCfgFile)
 
 	_ = Dialog{
 		AssignTo:      &dlg,
@@ -65,7 +72,7 @@ func EditDialog(owner walk.Form, indexes []int) (int, error) {
 					},
 					CheckBox{
 						AssignTo: &processCheck,
-						Checked:  item.Process,
+						Checked:  !item.HasReason(livery.ReasonDisabledByCustom),
 					},
 					Label{
 						Text: "Livery:",
@@ -74,6 +81,13 @@ func EditDialog(owner walk.Form, indexes []int) (int, error) {
 						Text:     item.AircraftCfgFile,
 						ReadOnly: true,
 					},
+					Label{
+						Text: "Package:",
+					},
+					LineEdit{
+						Text:     packageText(item.Package),
+						ReadOnly: true,
+					},
 					Label{
 						Text: "Base Container:",
 					},
@@ -81,6 +95,13 @@ func EditDialog(owner walk.Form, indexes []int) (int, error) {
 						Text:     item.BaseContainer,
 						ReadOnly: true,
 					},
+					Label{
+						Text: "Base Aircraft:",
+					},
+					LineEdit{
+						Text:     baseAircraftText(item.BaseAircraft),
+						ReadOnly: true,
+					},
 					Label{
 						Text: "Title:",
 					},
@@ -95,6 +116,14 @@ func EditDialog(owner walk.Form, indexes []int) (int, error) {
 						AssignTo: &customIcao,
 						Text:     item.Icao,
 					},
+					Label{
+						Text: "Weight:",
+					},
+					LineEdit{
+						AssignTo:    &weightEdit,
+						Text:        weight,
+						ToolTipText: "How often the title is repeated in its rules - 0 drops it, empty for the configured weight",
+					},
 				},
 			},
 			Composite{
@@ -105,22 +134,15 @@ func EditDialog(owner walk.Form, indexes []int) (int, error) {
 						AssignTo: &acceptPB,
 						Text:     "OK",
 						OnClicked: func() {
-							if processCheck.Checked() == item.Process && customIcao.Text() == item.Icao {
-								// no changes
+							newWeight, err := parseWeight(weightEdit.Text())
+							if err != nil {
+								walk.MsgBox(dlg, "Invalid weight", "The weight has to be a number of 0 or more or empty.", walk.MsgBoxIconError)
 								return
 							}
-
-							if customIcao.Text() != "" {
-								item.Custom = true
-								config.Configuration.Custom.AddOrChangeEntry(item.AircraftCfgFile, processCheck.Checked(), item.Icao, customIcao.Text())
-								item.Complete = true
-								item.Process = processCheck.Checked()
-								item.Icao = customIcao.Text()
-							} else {
-								item.Complete = false
-								item.Process = false
+							if !item.Edit(processCheck.Checked(), customIcao.Text(), newWeight) {
+								// no changes
+								return
 							}
-
 							dlg.Accept()
 						},
 					},
@@ -139,3 +161,47 @@ func EditDialog(owner walk.Form, indexes []int) (int, error) {
 	customIcao.SetFocus()
 	return dlg.Run(), nil
 }
+
+// weightText returns the weight of the custom data of a livery for the ui - empty if it has none
+func weightText(aircraftCfg string) string {
+	entry := config.Configuration.Custom.GetEntry(aircraftCfg)
+	if entry == nil || entry.Weight == config.NoWeight {
+		return ""
+	}
+	return strconv.Itoa(entry.Weight)
+}
+
+// parseWeight parses the weight entered in the ui - empty removes the weight
+func parseWeight(text string) (int, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return config.NoWeight, nil
+	}
+	weight, err := strconv.Atoi(text)
+	if err == nil && weight < 0 {
+		err = strconv.ErrRange
+	}
+	return weight, err
+}
+
+// packageText returns the package of a livery with its creator for the ui
+func packageText(p *livery.Package) string {
+	if p == nil {
+		return ""
+	}
+	if p.Creator == "" {
+		return fmt.Sprintf("%s (%s)", p.String(), p.Name)
+	}
+	return fmt.Sprintf("%s by %s (%s)", p.String(), p.Creator, p.Name)
+}
+
+// baseAircraftText returns the installed base aircraft of a livery with its ICAO meta data for the ui
+func baseAircraftText(a *livery.Aircraft) string {
+	if a == nil {
+		return "not installed"
+	}
+	if a.IcaoManufacturer == "" && a.IcaoModel == "" {
+		return fmt.Sprintf("%s [%s]", a.String(), a.Root)
+	}
+	return fmt.Sprintf("%s %s %s [%s]", a.String(), a.IcaoManufacturer, a.IcaoModel, a.Root)
+}
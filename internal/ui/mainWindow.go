@@ -34,7 +34,6 @@ import (
 	"strconv"
 
 	"github.com/frankkopp/MatchMaker/internal/config"
-	"github.com/frankkopp/MatchMaker/internal/rules"
 	"github.com/lxn/walk"
 	. "github.com/lxn/walk/declarative"
 )
@@ -68,8 +67,7 @@ func NewMainWindow() (*walk.MainWindow, error) {
 				OnCurrentIndexChanged: func() {
 					switch tabBarWidget.CurrentIndex() {
 					case 0:
-						scanButton.SetText(fmt.Sprintf("Scan: %s",
-							config.Configuration.Ini.Section("paths").Key("liveryDir").Value()))
+						scanButton.SetText(fmt.Sprintf("Scan: %s", liveryRootsText()))
 					case 1:
 						// ignore
 					case 2:
@@ -94,7 +92,7 @@ func NewMainWindow() (*walk.MainWindow, error) {
 	// store window state to ini when closing window
 	mainWindow.Closing().Attach(func(canceled *bool, reason walk.CloseReason) {
 		// Prompt user when rules not saved or configuration is not saved.
-		if config.Configuration.Dirty || rules.Dirty {
+		if config.Configuration.Dirty || model.rulesDirty {
 			dlg, err := SaveDialog(mainWindow)
 			if err != nil {
 				log.Print(err)
@@ -28,8 +28,11 @@
 package ui
 
 import (
+	"errors"
 	"fmt"
+	"path/filepath"
 	"sort"
+	"strings"
 
 	"github.com/frankkopp/MatchMaker/internal/config"
 	"github.com/frankkopp/MatchMaker/internal/livery"
@@ -45,7 +48,12 @@ type LiveryModel struct {
 	walk.SorterBase
 	sortColumn int
 	sortOrder  walk.SortOrder
-	items      []*livery.Livery
+	all        []*livery.Livery // all scanned liveries - used for the rules
+	items      []*livery.Livery // liveries shown in the table after applying the filters
+	filters    []livery.Filter
+	scanResult *livery.ScanResult
+	ruleSet    *rules.RuleSet // rules of the last calculation - nil before the first calculation
+	rulesDirty bool           // rules have changed since they have been saved
 }
 
 func NewLiveryModel() *LiveryModel {
@@ -57,34 +65,54 @@ func (m *LiveryModel) ScanLiveriesAction() {
 	tabBarWidget.SetEnabled(false)
 	scanButton.SetEnabled(false)
 	liveryTableView.SetEnabled(false)
-	StatusBar1.SetText(fmt.Sprintf("Scanning %s ...", config.Configuration.Ini.Section("paths").Key("liveryDir").Value()))
+	StatusBar1.SetText(fmt.Sprintf("Scanning %s ...", liveryRootsText()))
 	StatusBar5.SetText(fmt.Sprint("Rules not copied or saved yet."))
 	// use parallel execution to allow the ui to be responsive
 	go m.scanLiveries()
 }
 
 func (m *LiveryModel) scanLiveries() {
-	liveries, err := livery.ScanLiveryFolder(config.Configuration.Ini.Section("paths").Key("liveryDir").Value())
+	result, err := livery.ScanLiveryRoots(config.Configuration.LiveryRoots())
 	if err != nil {
 		StatusBar1.SetText(fmt.Sprintf("Scanning failed: %s ...", err))
 		m.onUpdateList()
 		return
 	}
-	m.items = liveries
-	m.Sort(m.sortColumn, m.sortOrder)
+	if len(result.Archived) > 0 {
+		result.ArchiveGaps = rules.NewEngine(rules.NewConfig(&config.Configuration)).ArchiveGaps(result.Liveries, result.Archived)
+	}
+	m.scanResult = result
+	// liveries in archives are shown as well but are never processed
+	m.all = append(append([]*livery.Livery{}, result.Liveries...), result.Archived...)
+	m.applyFilters()
 	m.onUpdateList()
 }
 
+// SetFilters sets the filters for the liveries shown in the table. The rules
+// are always calculated from all liveries.
+func (m *LiveryModel) SetFilters(filters []livery.Filter) {
+	m.filters = filters
+	m.applyFilters()
+	m.PublishRowsReset()
+	m.updateFoundStatus()
+}
+
+func (m *LiveryModel) applyFilters() {
+	m.items = livery.FilterLiveries(m.all, m.filters)
+	m.Sort(m.sortColumn, m.sortOrder)
+}
+
 // called every time when there is a change in the list of liveries
 func (m *LiveryModel) onUpdateList() {
 	tabBarWidget.SetEnabled(false)
 	scanButton.SetEnabled(false)
 	liveryTableView.SetEnabled(false)
 	m.PublishRowsReset()
-	StatusBar1.SetText(fmt.Sprintf("Number of liveries found: %d", m.RowCount()))
+	m.updateFoundStatus()
 	StatusBar2.SetText(fmt.Sprintf("Number of liveries queued: %d", m.QueuedCount()))
-	rules.CalculateRules(m.items)
-	StatusBar3.SetText(fmt.Sprintf("Generating %d mappings...", rules.Counter))
+	m.ruleSet = m.calculateRules()
+	m.rulesDirty = true
+	StatusBar3.SetText(fmt.Sprintf("Generating %d mappings...", m.ruleSet.Stats().Mappings))
 	StatusBar4.SetText(fmt.Sprint("Generating XML lines..."))
 	if config.Configuration.Dirty {
 		StatusBar6.SetText(fmt.Sprint("Configuration not saved yet."))
@@ -92,18 +120,124 @@ func (m *LiveryModel) onUpdateList() {
 		configTabPage.SizeChanged()
 	}
 	// use parallel execution to allow the ui to be responsive
-	go m.buildXML()
+	go m.buildXML(m.ruleSet)
+}
+
+// called when only the liveries of some ICAOs or the default liveries ("")
+// have changed - only their rules are calculated and generated again
+func (m *LiveryModel) onUpdateLiveries(icaos ...string) {
+	// merged import files are only handled by a complete calculation
+	if m.ruleSet == nil || len(config.Configuration.ImportFiles()) > 0 {
+		m.onUpdateList()
+		return
+	}
+	m.PublishRowsReset()
+	m.updateFoundStatus()
+	StatusBar2.SetText(fmt.Sprintf("Number of liveries queued: %d", m.QueuedCount()))
+	m.ruleSet = rules.NewEngine(rules.NewConfig(&config.Configuration)).Update(m.ruleSet, m.all, icaos...)
+	m.rulesDirty = true
+	if config.Configuration.Dirty {
+		StatusBar6.SetText(fmt.Sprint("Configuration not saved yet."))
+		if !strings.HasSuffix(configTabPage.Name(), " (changed)") {
+			configTabPage.SetName(configTabPage.Name() + " (changed)")
+			configTabPage.SizeChanged()
+		}
+	}
+	output, numberOfLines := m.ruleSet.GenerateXML()
+	rulesText.SetText(output)
+	StatusBar3.SetText(fmt.Sprintf("Generated %d mappings.", m.ruleSet.Stats().Mappings))
+	StatusBar4.SetText(fmt.Sprintf("Generated %d rule lines.", numberOfLines))
+	StatusBar5.SetText(fmt.Sprint("Rules not copied or saved yet."))
+}
+
+// calculates the rules from all liveries and merges the rules of the import
+// files - problems with the import files are shown in the status bar
+func (m *LiveryModel) calculateRules() *rules.RuleSet {
+	ruleSet := rules.NewEngine(rules.NewConfig(&config.Configuration)).Calculate(m.all)
+	if conflicts := config.Configuration.UnresolvedIcaoConflicts(); len(conflicts) > 0 {
+		StatusBar6.SetText(fmt.Sprintf("%d conflicts in [icaoVariations] - first: %s", len(conflicts), conflicts[0]))
+	}
+	files := config.Configuration.ImportFiles()
+	if len(files) == 0 {
+		return ruleSet
+	}
+	merged, issues, err := ruleSet.MergeFiles(files, config.Configuration.MergeStrategy())
+	switch {
+	case err != nil:
+		StatusBar6.SetText(fmt.Sprintf("Importing rules failed: %s", err))
+		return ruleSet
+	case len(issues) > 0:
+		StatusBar6.SetText(fmt.Sprintf("%d problems in the import files - first: %s", len(issues), issues[0]))
+	}
+	return merged
+}
+
+// RulesDiff compares the calculated rules with the output file and returns a
+// one line summary and the complete report of the changes
+func (m *LiveryModel) RulesDiff() (string, string) {
+	if m.ruleSet == nil {
+		return "no rules calculated yet", ""
+	}
+	outputFile := config.Configuration.Ini.Section("paths").Key("outputFile").String()
+	diff, _, err := m.ruleSet.DiffFile(outputFile)
+	if err != nil {
+		return fmt.Sprintf("could not compare with %s: %s", outputFile, err), ""
+	}
+	var report strings.Builder
+	_ = diff.WriteReport(&report) // writing to a strings.Builder can't fail
+	return diff.Summary(), strings.ReplaceAll(report.String(), "\n", "\r\n")
+}
+
+// SaveRules saves the rules of the last calculation to the configured output file
+func (m *LiveryModel) SaveRules() error {
+	if m.ruleSet == nil {
+		return errors.New("no rules calculated yet - scan for liveries first")
+	}
+	if err := m.ruleSet.SaveToFile(config.Configuration.Ini.Section("paths").Key("outputFile").String()); err != nil {
+		return err
+	}
+	m.rulesDirty = false
+	return nil
+}
+
+// shows the number of liveries found, skipped and shown in the status bar
+func (m *LiveryModel) updateFoundStatus() {
+	installed := len(m.all)
+	if m.scanResult != nil {
+		installed = len(m.scanResult.Liveries)
+	}
+	found := fmt.Sprintf("Number of liveries found: %d", installed)
+	StatusBar1.SetToolTipText("")
+	if m.scanResult != nil && (len(m.scanResult.Issues) > 0 || len(m.scanResult.Pruned) > 0 || len(m.scanResult.Archived) > 0) {
+		found = fmt.Sprintf("Liveries found: %d (%d files skipped)", installed, m.scanResult.SkippedFiles())
+		lines := m.scanResult.IssueSummary()
+		if len(m.scanResult.Pruned) > 0 {
+			lines = append(append(lines, "Pruned paths:"), m.scanResult.PrunedSummary()...)
+		}
+		if len(m.scanResult.Archived) > 0 {
+			found += fmt.Sprintf(" + %d in archives", len(m.scanResult.Archived))
+			lines = append(lines, fmt.Sprintf("Archives filling gaps: %d", len(m.scanResult.ArchiveGaps)))
+			for _, gap := range m.scanResult.ArchiveGaps {
+				lines = append(lines, fmt.Sprintf("%5d %s", len(gap.Fills), filepath.Base(gap.Archive)))
+			}
+		}
+		StatusBar1.SetToolTipText(strings.Join(lines, "\r\n"))
+	}
+	if len(m.filters) > 0 {
+		found += fmt.Sprintf(" - %d shown", m.RowCount())
+	}
+	StatusBar1.SetText(found)
 }
 
 // builds the actual XML from the calculated rules
-func (m *LiveryModel) buildXML() {
+func (m *LiveryModel) buildXML(ruleSet *rules.RuleSet) {
 	rulesText.SetText("")
 
-	output, numberOfLines := rules.GenerateXML()
+	output, numberOfLines := ruleSet.GenerateXML()
 
 	// show in view
-	rulesText.SetText(output.String())
-	StatusBar3.SetText(fmt.Sprintf("Generated %d mappings.", rules.Counter))
+	rulesText.SetText(output)
+	StatusBar3.SetText(fmt.Sprintf("Generated %d mappings.", ruleSet.Stats().Mappings))
 	StatusBar4.SetText(fmt.Sprintf("Generated %d rule lines.", numberOfLines))
 	StatusBar5.SetText(fmt.Sprint("Rules not copied or saved yet."))
 
@@ -115,8 +249,8 @@ func (m *LiveryModel) buildXML() {
 
 func (m *LiveryModel) QueuedCount() int {
 	i := 0
-	for _, item := range m.items {
-		if item.Process && item.Complete {
+	for _, item := range m.all {
+		if item.Included() {
 			i++
 		}
 	}
@@ -133,7 +267,7 @@ func (m *LiveryModel) Value(row, col int) interface{} {
 	item := m.items[row]
 	switch col {
 	case 0:
-		return item.Process
+		return item.Included()
 	case 1:
 		return item.Custom
 	case 2:
@@ -143,6 +277,32 @@ func (m *LiveryModel) Value(row, col int) interface{} {
 	case 4:
 		return item.BaseContainer
 	case 5:
+		return item.Root
+	case 6:
+		return item.Package.String()
+	case 7:
+		return item.Remark()
+	case 8:
+		return item.AtcId
+	case 9:
+		return item.AtcAirline
+	case 10:
+		return item.AtcParkingCodes
+	case 11:
+		return item.AtcParkingTypes
+	case 12:
+		return item.UiType
+	case 13:
+		return item.UiVariation
+	case 14:
+		return item.UiManufacturer
+	case 15:
+		return item.IsAirTraffic
+	case 16:
+		return item.IsUserSelectable
+	case 17:
+		return item.Category
+	case 18:
 		return item.AircraftCfgFile
 	}
 	panic("unexpected col")
@@ -162,24 +322,10 @@ func (m *LiveryModel) Sort(col int, order walk.SortOrder) error {
 			return !ls
 		}
 
-		// sort for Process first the Complete
+		// sort by status - included liveries first
 		switch m.sortColumn {
 		case 0:
-			aS := 0
-			bS := 0
-			if a.Process {
-				aS += 2
-			}
-			if a.Complete {
-				aS++
-			}
-			if b.Process {
-				bS += 2
-			}
-			if b.Complete {
-				bS++
-			}
-			return compare(aS > bS)
+			return compare(a.Status() < b.Status())
 		case 1:
 			return compare(a.Custom)
 		case 2:
@@ -189,6 +335,32 @@ func (m *LiveryModel) Sort(col int, order walk.SortOrder) error {
 		case 4:
 			return compare(a.BaseContainer < b.BaseContainer)
 		case 5:
+			return compare(a.Root < b.Root)
+		case 6:
+			return compare(a.Package.String() < b.Package.String())
+		case 7:
+			return compare(a.Remark() < b.Remark())
+		case 8:
+			return compare(a.AtcId < b.AtcId)
+		case 9:
+			return compare(a.AtcAirline < b.AtcAirline)
+		case 10:
+			return compare(a.AtcParkingCodes < b.AtcParkingCodes)
+		case 11:
+			return compare(a.AtcParkingTypes < b.AtcParkingTypes)
+		case 12:
+			return compare(a.UiType < b.UiType)
+		case 13:
+			return compare(a.UiVariation < b.UiVariation)
+		case 14:
+			return compare(a.UiManufacturer < b.UiManufacturer)
+		case 15:
+			return compare(a.IsAirTraffic && !b.IsAirTraffic)
+		case 16:
+			return compare(a.IsUserSelectable && !b.IsUserSelectable)
+		case 17:
+			return compare(a.Category < b.Category)
+		case 18:
 			return compare(a.AircraftCfgFile < b.AircraftCfgFile)
 		}
 		panic("unreachable")
@@ -198,6 +370,8 @@ func (m *LiveryModel) Sort(col int, order walk.SortOrder) error {
 }
 
 func (m *LiveryModel) Clear() {
+	m.all = []*livery.Livery{}
 	m.items = []*livery.Livery{}
+	m.scanResult = nil
 	m.onUpdateList()
 }
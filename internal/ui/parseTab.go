@@ -29,8 +29,11 @@ package ui
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/frankkopp/MatchMaker/internal/config"
+	"github.com/frankkopp/MatchMaker/internal/livery"
 	"github.com/lxn/walk"
 	. "github.com/lxn/walk/declarative"
 )
@@ -39,6 +42,7 @@ var (
 	parseTabPage    *walk.TabPage
 	liveryTableView *walk.TableView
 	scanButton      *walk.PushButton
+	filterLineEdit  *walk.LineEdit
 
 	model = NewLiveryModel()
 )
@@ -52,9 +56,20 @@ func parseTab() TabPage {
 		Children: []Widget{
 			PushButton{
 				AssignTo:  &scanButton,
-				Text:      fmt.Sprintf("Scan: %s", config.Configuration.Ini.Section("paths").Key("liveryDir").Value()),
+				Text:      fmt.Sprintf("Scan: %s", liveryRootsText()),
 				OnClicked: model.ScanLiveriesAction,
 			},
+			Composite{
+				Layout: HBox{MarginsZero: true},
+				Children: []Widget{
+					Label{Text: "Filter:"},
+					LineEdit{
+						AssignTo:          &filterLineEdit,
+						ToolTipText:       filterToolTip,
+						OnEditingFinished: OnFilterChanged,
+					},
+				},
+			},
 			TableView{
 				AssignTo:            &liveryTableView,
 				AlternatingRowBG:    true,
@@ -70,7 +85,20 @@ func parseTab() TabPage {
 					{Title: "Custom", Width: 50, Alignment: AlignCenter},
 					{Title: "ICAO", Width: 50},
 					{Title: "Title (blue=default livery)", Width: 240},
-					{Title: "Base Container (red=no default type)", Width: 220},
+					{Title: "Base Container (red=no default type, grey=not installed)", Width: 220},
+					{Title: "Root (grey=duplicate)", Width: 100},
+					{Title: "Package", Width: 200},
+					{Title: "Remark", Width: 200},
+					{Title: "ATC Id", Width: 80, Hidden: !showExtendedColumns()},
+					{Title: "ATC Airline", Width: 120, Hidden: !showExtendedColumns()},
+					{Title: "Parking Codes", Width: 80, Hidden: !showExtendedColumns()},
+					{Title: "Parking Types", Width: 80, Hidden: !showExtendedColumns()},
+					{Title: "UI Type", Width: 150, Hidden: !showExtendedColumns()},
+					{Title: "UI Variation", Width: 200, Hidden: !showExtendedColumns()},
+					{Title: "UI Manufacturer", Width: 100, Hidden: !showExtendedColumns()},
+					{Title: "AI Traffic", Width: 60, Alignment: AlignCenter, Hidden: !showExtendedColumns()},
+					{Title: "User Selectable", Width: 60, Alignment: AlignCenter, Hidden: !showExtendedColumns()},
+					{Title: "Category", Width: 80, Hidden: !showExtendedColumns()},
 					{Title: "Livery Configuration File (green=custom configured", Width: 650},
 				},
 				StyleCell: func(style *walk.CellStyle) {
@@ -78,11 +106,14 @@ func parseTab() TabPage {
 
 					// style individual cell
 					switch style.Col() {
-					case 0: // process
-						if item.Complete {
+					case 0: // status
+						switch item.Status() {
+						case livery.StatusIncluded, livery.StatusDisabled:
 							style.BackgroundColor = walk.RGB(162, 202, 112)
-						} else {
+						case livery.StatusIncomplete:
 							style.BackgroundColor = walk.RGB(204, 102, 102)
+						default:
+							style.BackgroundColor = walk.RGB(190, 190, 190)
 						}
 					case 1: // Custom
 					case 2: // ICAO
@@ -91,11 +122,22 @@ func parseTab() TabPage {
 							style.TextColor = walk.RGB(0, 0, 255)
 						}
 					case 4: // Base Container
-						// mark base containers which are not configured to be mapped
+						// mark base containers which are not configured to be mapped or not installed
 						if !config.Configuration.Ini.Section("defaultTypes").HasKey(item.BaseContainer) {
 							style.TextColor = walk.RGB(146, 43, 33)
+						} else if item.BaseMissing {
+							style.TextColor = walk.RGB(150, 150, 150)
+						}
+					case 5: // Root
+						if item.DuplicateOf != "" {
+							style.TextColor = walk.RGB(150, 150, 150)
 						}
-					case 5: // Config File
+					case 6: // Package
+					case 7: // Remark
+						if item.Blocked() {
+							style.TextColor = walk.RGB(150, 150, 150)
+						}
+					case 18: // Config File
 						if item.Custom {
 							style.TextColor = walk.RGB(0, 130, 40)
 						}
@@ -127,12 +169,22 @@ func parseTab() TabPage {
 						Text:        "Remove from Default",
 						OnTriggered: OnItemRemoveDefaultAction,
 					},
+					Separator{},
+					Action{
+						Text:        "Show/Hide FLTSIM Columns",
+						OnTriggered: OnToggleExtendedColumnsAction,
+					},
 				},
 				OnItemActivated: func() {
-					if model.items[liveryTableView.CurrentIndex()].Process {
-						OnItemDeactivatedAction()
-					} else {
+					item := model.items[liveryTableView.CurrentIndex()]
+					switch {
+					case !item.Toggleable():
+						// excluded for another reason - custom data would not change this
+						walk.MsgBox(mainWindow, "Livery not used", item.Title+":\r\n"+item.Remark(), walk.MsgBoxIconInformation)
+					case item.HasReason(livery.ReasonDisabledByCustom):
 						OnItemActivatedAction()
+					default:
+						OnItemDeactivatedAction()
 					}
 				},
 			},
@@ -140,6 +192,65 @@ func parseTab() TabPage {
 	}
 }
 
+// the columns with the additional FLTSIM meta data which can be shown or hidden
+const (
+	firstExtendedColumn = 8
+	lastExtendedColumn  = 17
+)
+
+// showExtendedColumns returns true if the columns with the additional FLTSIM
+// meta data should be shown
+func showExtendedColumns() bool {
+	return config.Configuration.Ini.Section("application").Key("extendedColumns").MustBool(false)
+}
+
+// OnToggleExtendedColumnsAction shows or hides the columns with the additional
+// FLTSIM meta data and remembers the setting in the configuration
+func OnToggleExtendedColumnsAction() {
+	show := !showExtendedColumns()
+	columns := liveryTableView.Columns()
+	for i := firstExtendedColumn; i <= lastExtendedColumn && i < columns.Len(); i++ {
+		if err := columns.At(i).SetVisible(show); err != nil {
+			fmt.Printf("Could not change column visibility: %s\n", err)
+		}
+	}
+	config.Configuration.Ini.Section("application").Key("extendedColumns").SetValue(strconv.FormatBool(show))
+}
+
+// OnFilterChanged parses the filter text and applies the filters to the table.
+// Filters are separated by ";" and have the form field=value or field!=value.
+// Text without a field filters the title.
+func OnFilterChanged() {
+	var filters []livery.Filter
+	for _, expr := range strings.Split(filterLineEdit.Text(), ";") {
+		if expr = strings.TrimSpace(expr); expr == "" {
+			continue
+		}
+		if !strings.Contains(expr, "=") {
+			expr = "title=" + expr
+		}
+		filter, err := livery.ParseFilter(expr)
+		if err != nil {
+			filterLineEdit.SetToolTipText(err.Error())
+			return
+		}
+		filters = append(filters, filter)
+	}
+	filterLineEdit.SetToolTipText(filterToolTip)
+	model.SetFilters(filters)
+}
+
+const filterToolTip = "Filters separated by \";\" as field=value or field!=value (text without field filters the title)"
+
+// liveryRootsText returns the enabled livery roots as text for the ui
+func liveryRootsText() string {
+	var roots []string
+	for _, root := range config.Configuration.EnabledLiveryRoots() {
+		roots = append(roots, fmt.Sprintf("%s (%s)", root.Path, root.Label))
+	}
+	return strings.Join(roots, ", ")
+}
+
 func OnItemAddDefaultAction() {
 	if len(liveryTableView.SelectedIndexes()) == 0 {
 		return
@@ -151,7 +262,7 @@ func OnItemAddDefaultAction() {
 		}
 		config.Configuration.AddLiveryToDefault(item.BaseContainer, item.Title)
 	}
-	model.onUpdateList()
+	model.onUpdateLiveries("")
 }
 
 func OnItemRemoveDefaultAction() {
@@ -165,7 +276,7 @@ func OnItemRemoveDefaultAction() {
 		}
 		config.Configuration.RemoveLiveryFromDefault(item.BaseContainer, item.Title)
 	}
-	model.onUpdateList()
+	model.onUpdateLiveries("")
 }
 
 func OnItemRemoveCustomAction() {
@@ -185,12 +296,12 @@ func OnItemRemoveCustomAction() {
 	if !config.Configuration.Custom.HasEntry(aircraftCfgFile) {
 		return
 	}
-	selectedItem.Icao = config.Configuration.Custom.GetEntry(aircraftCfgFile).OriginalIcao
-	selectedItem.Custom = false
+	customIcao := selectedItem.Icao
+	selectedItem.RemoveCustomData(config.Configuration.Custom.GetEntry(aircraftCfgFile).OriginalIcao)
 	if err := config.Configuration.Custom.RemoveEntry(aircraftCfgFile); err != nil {
 		fmt.Printf("Could not remove entry for: %s\n", aircraftCfgFile)
 	}
-	model.onUpdateList()
+	model.onUpdateLiveries(customIcao, selectedItem.Icao)
 }
 
 func OnItemEditAction() {
@@ -204,35 +315,42 @@ func OnItemEditAction() {
 		return
 	}
 
+	item := model.items[first]
+	icao := item.Icao
 	if dlg, err := EditDialog(mainWindow, liveryTableView.SelectedIndexes()); err != nil {
 		fmt.Print(err)
 	} else if dlg == walk.DlgCmdOK {
-		model.onUpdateList()
+		model.onUpdateLiveries(icao, item.Icao)
 	}
 }
 
 func OnItemDeactivatedAction() {
 	customData := config.Configuration.Custom
+	var icaos []string
 	// multiple selected items allowed and iterated over
 	for _, i := range liveryTableView.SelectedIndexes() {
 		item := model.items[i]
-		item.Process = false
+		item.SetReason(livery.ReasonDisabledByCustom, true)
 		item.Custom = true
 		// add this entry to custom-data now that is has been altered
-		customData.SetProcessFlag(item.AircraftCfgFile, item.Process, item.Icao)
+		customData.SetProcessFlag(item.AircraftCfgFile, false, item.Icao)
+		icaos = append(icaos, item.Icao)
 	}
-	model.onUpdateList()
+	model.onUpdateLiveries(icaos...)
 }
 
 func OnItemActivatedAction() {
 	customData := config.Configuration.Custom
+	var icaos []string
 	for _, i := range liveryTableView.SelectedIndexes() {
 		item := model.items[i]
-		if item.Complete {
-			item.Process = true
+		// duplicates, liveries of disabled packages and excluded variations are never used
+		if item.Complete() && !item.Blocked() {
+			item.SetReason(livery.ReasonDisabledByCustom, false)
 			item.Custom = true
-			customData.SetProcessFlag(item.AircraftCfgFile, item.Process, item.Icao)
+			customData.SetProcessFlag(item.AircraftCfgFile, true, item.Icao)
+			icaos = append(icaos, item.Icao)
 		}
 	}
-	model.onUpdateList()
+	model.onUpdateLiveries(icaos...)
 }
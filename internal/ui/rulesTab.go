@@ -31,7 +31,6 @@ import (
 	"fmt"
 
 	"github.com/frankkopp/MatchMaker/internal/config"
-	"github.com/frankkopp/MatchMaker/internal/rules"
 	// "github.com/lxn/walk"
 	"github.com/lxn/walk"
 	. "github.com/lxn/walk/declarative"
@@ -74,12 +73,18 @@ func rulesTab() TabPage {
 					PushButton{
 						Text: "Save",
 						OnClicked: func() {
-							err := rules.SaveRulesToFile()
+							// the changes compared to the file are shown before saving
+							diffSummary, diffReport := model.RulesDiff()
+							if cmd, err := SaveRulesDialog(mainWindow, diffSummary, diffReport); err != nil || cmd != walk.DlgCmdOK {
+								return
+							}
+							err := model.SaveRules()
 							if err != nil {
 								StatusBar5.SetText(fmt.Sprintf("Saving rules to %s failed: %s", config.Configuration.Ini.Section("paths").Key("outputFile").Value(), err))
 								return
 							}
-							StatusBar5.SetText(fmt.Sprintf("Rules saved to file: %s", config.Configuration.Ini.Section("paths").Key("outputFile").Value()))
+							StatusBar5.SetText(fmt.Sprintf("Rules saved to file: %s (%s)", config.Configuration.Ini.Section("paths").Key("outputFile").Value(), diffSummary))
+							StatusBar5.SetToolTipText(diffReport)
 						},
 					},
 				},
@@ -31,7 +31,6 @@ import (
 	"fmt"
 
 	"github.com/frankkopp/MatchMaker/internal/config"
-	"github.com/frankkopp/MatchMaker/internal/rules"
 	"github.com/lxn/walk"
 	. "github.com/lxn/walk/declarative"
 )
@@ -40,6 +39,8 @@ func SaveDialog(owner walk.Form) (int, error) {
 	var dlg *walk.Dialog
 	var acceptPB, cancelPB *walk.PushButton
 
+	diffSummary, diffReport := model.RulesDiff()
+
 	var (
 		configSave *walk.CheckBox
 		rulesSave  *walk.CheckBox
@@ -69,7 +70,14 @@ func SaveDialog(owner walk.Form) (int, error) {
 					},
 					CheckBox{
 						AssignTo: &rulesSave,
-						Checked:  rules.Dirty,
+						Checked:  model.rulesDirty,
+					},
+					Label{
+						Text: "Changed rules:",
+					},
+					TextLabel{
+						Text:        diffSummary,
+						ToolTipText: diffReport,
 					},
 					TextLabel{
 						AssignTo:      &errorMsg,
@@ -101,7 +109,7 @@ func SaveDialog(owner walk.Form) (int, error) {
 								}
 							}
 							if rulesSave.Checked() {
-								err := rules.SaveRulesToFile()
+								err := model.SaveRules()
 								if err != nil {
 									errMsg := fmt.Sprintf("Saving rules to \"%s\" failed: %s", config.Configuration.Ini.Section("paths").Key("outputFile").Value(), err)
 									StatusBar5.SetText(errMsg)
@@ -135,3 +143,75 @@ func SaveDialog(owner walk.Form) (int, error) {
 
 	return dlg.Run(), nil
 }
+
+// SaveRulesDialog shows the changes of the calculated rules compared to the
+// output file before they are saved. Returns walk.DlgCmdOK if the rules should
+// be saved.
+func SaveRulesDialog(owner walk.Form, diffSummary, diffReport string) (int, error) {
+	var dlg *walk.Dialog
+	var acceptPB, cancelPB *walk.PushButton
+
+	if diffReport == "" {
+		diffReport = diffSummary
+	}
+
+	_ = Dialog{
+		AssignTo:      &dlg,
+		Title:         "Saving rules",
+		MinSize:       Size{Width: 600, Height: 400},
+		DefaultButton: &cancelPB,
+		CancelButton:  &cancelPB,
+		Layout:        VBox{},
+		Children: []Widget{
+			Composite{
+				Layout: Grid{Columns: 2},
+				Children: []Widget{
+					Label{
+						Text: "Rules file:",
+					},
+					TextLabel{
+						Text: config.Configuration.Ini.Section("paths").Key("outputFile").Value(),
+					},
+					Label{
+						Text: "Changed rules:",
+					},
+					TextLabel{
+						Text: diffSummary,
+					},
+				},
+			},
+			TextEdit{
+				Text:     diffReport,
+				ReadOnly: true,
+				VScroll:  true,
+				HScroll:  true,
+				Font: Font{
+					Family:    "Lucida Sans Typewriter",
+					PointSize: 8,
+				},
+			},
+			Composite{
+				Layout: HBox{},
+				Children: []Widget{
+					HSpacer{},
+					PushButton{
+						AssignTo: &acceptPB,
+						Text:     "Save",
+						OnClicked: func() {
+							dlg.Accept()
+						},
+					},
+					PushButton{
+						AssignTo: &cancelPB,
+						Text:     "Cancel",
+						OnClicked: func() {
+							dlg.Cancel()
+						},
+					},
+				},
+			},
+		},
+	}.Create(owner)
+
+	return dlg.Run(), nil
+}
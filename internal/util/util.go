@@ -62,6 +62,16 @@ func SaveToFile(outPutFile string, output strings.Builder) error {
 	return nil
 }
 
+// WriteFileAtomic writes data to a temporary file and then renames it to the
+// given path so a crash while writing does not leave a truncated file behind
+func WriteFileAtomic(path string, data []byte) error {
+	tmpFile := path + ".tmp"
+	if err := ioutil.WriteFile(tmpFile, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, path)
+}
+
 // IsDir checks if the given path is a directory
 func IsDir(path string) (bool, error) {
 	info, err := os.Stat(path)
@@ -0,0 +1,214 @@
+/*
+ * MatchMaker - create model matching files for VATSIM vPilot
+ *
+ *  MIT License
+ *
+ *  Copyright (c) 2021 Frank Kopp
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NON-INFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ *
+ */
+
+// Package watch watches directory trees and single files for changes and reports
+// the changes in batches after they have settled. Installing or removing a livery
+// package creates a burst of file system events which is reported as one batch.
+package watch
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/karrick/godirwalk"
+)
+
+// Watcher watches directory trees recursively and single files. fsnotify only
+// watches single directories so every directory of a tree is watched and new
+// directories are added when they are created.
+type Watcher struct {
+	fs       *fsnotify.Watcher
+	debounce time.Duration
+	trees    []string        // root directories watched recursively
+	files    map[string]bool // single files - their directory is watched
+	ignore   map[string]bool // files never reported, e.g. files written by the application
+	dirs     map[string]bool // all directories watched
+}
+
+// New creates a watcher which reports changes after no further change happened
+// for the debounce duration.
+func New(debounce time.Duration) (*Watcher, error) {
+	fs, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	return &Watcher{
+		fs:       fs,
+		debounce: debounce,
+		files:    map[string]bool{},
+		ignore:   map[string]bool{},
+		dirs:     map[string]bool{},
+	}, nil
+}
+
+// AddTree watches the directory and all its sub directories. Trees and files
+// have to be added before Run is called.
+func (w *Watcher) AddTree(root string) error {
+	root = absPath(root)
+	w.trees = append(w.trees, root)
+	return w.addDirs(root)
+}
+
+// AddFile watches a single file. The directory of the file is watched as editors
+// often replace a file instead of writing it.
+func (w *Watcher) AddFile(path string) error {
+	path = absPath(path)
+	w.files[path] = true
+	return w.addDir(filepath.Dir(path))
+}
+
+// Ignore excludes a file from the reported changes
+func (w *Watcher) Ignore(path string) {
+	w.ignore[absPath(path)] = true
+}
+
+// Close stops watching
+func (w *Watcher) Close() error {
+	return w.fs.Close()
+}
+
+// Run reports the changed paths in batches to onChange until done is closed.
+// A batch is reported when no further change happened for the debounce duration.
+// The paths of a batch are absolute, sorted and unique.
+func (w *Watcher) Run(done <-chan struct{}, onChange func(changed []string)) {
+	pending := map[string]bool{}
+	timer := time.NewTimer(w.debounce)
+	timer.Stop()
+	for {
+		select {
+		case <-done:
+			timer.Stop()
+			return
+		case event, ok := <-w.fs.Events:
+			if !ok {
+				return
+			}
+			if !w.handle(event) {
+				continue
+			}
+			pending[filepath.Clean(event.Name)] = true
+			timer.Reset(w.debounce)
+		case err, ok := <-w.fs.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("Watching for changes failed: %v", err)
+		case <-timer.C:
+			changed := make([]string, 0, len(pending))
+			for p := range pending {
+				changed = append(changed, p)
+			}
+			sort.Strings(changed)
+			pending = map[string]bool{}
+			onChange(changed)
+		}
+	}
+}
+
+// handle keeps the watched directories up to date and returns true if the
+// event should be reported
+func (w *Watcher) handle(event fsnotify.Event) bool {
+	name := filepath.Clean(event.Name)
+	if w.ignore[name] {
+		return false
+	}
+	if w.files[name] {
+		return true
+	}
+	if !w.inTree(name) {
+		return false
+	}
+	switch {
+	case event.Op&fsnotify.Create != 0:
+		// new directories (e.g. a new package) have to be watched as well
+		if info, err := os.Stat(name); err == nil && info.IsDir() {
+			if err := w.addDirs(name); err != nil {
+				log.Printf("Could not watch %s: %v", name, err)
+			}
+		}
+	case event.Op&(fsnotify.Remove|fsnotify.Rename) != 0:
+		// fsnotify removes the watch of deleted directories itself
+		for dir := range w.dirs {
+			if dir == name || strings.HasPrefix(dir, name+string(filepath.Separator)) {
+				delete(w.dirs, dir)
+			}
+		}
+	case event.Op == fsnotify.Chmod:
+		return false
+	}
+	return true
+}
+
+// inTree returns true if the path is within one of the watched trees
+func (w *Watcher) inTree(path string) bool {
+	for _, root := range w.trees {
+		if path == root || strings.HasPrefix(path, root+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// addDirs watches the directory and all its sub directories
+func (w *Watcher) addDirs(root string) error {
+	return godirwalk.Walk(root, &godirwalk.Options{
+		Callback: func(osPathname string, de *godirwalk.Dirent) error {
+			if isDir, _ := de.IsDirOrSymlinkToDir(); isDir {
+				return w.addDir(osPathname)
+			}
+			return nil
+		},
+		Unsorted:            true,
+		FollowSymbolicLinks: true,
+	})
+}
+
+func (w *Watcher) addDir(dir string) error {
+	dir = filepath.Clean(dir)
+	if w.dirs[dir] {
+		return nil
+	}
+	if err := w.fs.Add(dir); err != nil {
+		return err
+	}
+	w.dirs[dir] = true
+	return nil
+}
+
+// absPath returns the absolute path as all events are reported with the path
+// the directory was added with
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
@@ -0,0 +1,96 @@
+/*
+ * MatchMaker - create model matching files for VATSIM vPilot
+ *
+ *  MIT License
+ *
+ *  Copyright (c) 2021 Frank Kopp
+ *
+ *  Permission is hereby granted, free of charge, to any person obtaining a copy
+ *  of this software and associated documentation files (the "Software"), to deal
+ *  in the Software without restriction, including without limitation the rights
+ *  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *  copies of the Software, and to permit persons to whom the Software is
+ *  furnished to do so, subject to the following conditions:
+ *
+ *  The above copyright notice and this permission notice shall be included in all
+ *  copies or substantial portions of the Software.
+ *
+ *  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *  FITNESS FOR A PARTICULAR PURPOSE AND NON-INFRINGEMENT. IN NO EVENT SHALL THE
+ *  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *  SOFTWARE.
+ *
+ */
+
+package watch
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatcher(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	root := filepath.Join(tmp, "Community")
+	if err := os.Mkdir(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+	iniFile := filepath.Join(tmp, "matchmaker.ini")
+	outputFile := filepath.Join(tmp, "rules.vmr")
+
+	w, err := New(100 * time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if err := w.AddTree(root); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.AddFile(iniFile); err != nil {
+		t.Fatal(err)
+	}
+	w.Ignore(filepath.Join(root, "ignored.txt"))
+
+	batches := make(chan []string, 10)
+	done := make(chan struct{})
+	defer close(done)
+	go w.Run(done, func(changed []string) { batches <- changed })
+
+	// a new package with a livery - the new folder has to be watched to see the aircraft.cfg
+	pkg := filepath.Join(root, "new-livery-pack")
+	if err := os.Mkdir(pkg, 0755); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	cfg := filepath.Join(pkg, "aircraft.cfg")
+	for _, f := range []string{cfg, iniFile, outputFile, filepath.Join(root, "ignored.txt")} {
+		if err := ioutil.WriteFile(f, []byte("[VARIATION]\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	select {
+	case changed := <-batches:
+		want := map[string]bool{pkg: true, cfg: true, iniFile: true}
+		if len(changed) != len(want) {
+			t.Errorf("changed = %v, want %v", changed, want)
+		}
+		for _, c := range changed {
+			if !want[c] {
+				t.Errorf("unexpected change reported: %s", c)
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no changes reported")
+	}
+}